@@ -0,0 +1,64 @@
+package buffer
+
+import (
+	"io"
+	"time"
+)
+
+type batchReader struct {
+	src   *reader
+	max   int
+	delay time.Duration
+}
+
+// NewBatchReader returns a new io.Reader that emits b in batches. Once data
+// is available Read keeps collecting for up to maxDelay or until maxBytes are
+// available, whichever comes first. EOF and reset end the wait early; at EOF
+// the collected data is returned before io.EOF. A maxBytes of zero or less
+// leaves batches limited by len(p) only. The reader also implements
+// io.Closer.
+func NewBatchReader(b *Buffer, maxBytes int, maxDelay time.Duration) io.Reader {
+	return &batchReader{src: NewReader(b).(*reader), max: maxBytes, delay: maxDelay}
+}
+
+func (r *batchReader) Read(p []byte) (n int, err error) {
+	if r.max > 0 && r.max < len(p) {
+		p = p[:r.max]
+	}
+
+	rr := r.src
+	defer func() { rr.drained(err) }()
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+
+	// Wait for a full batch or EOF or reset or the delay since first data.
+	var until time.Time
+	for (rr.ended() == nil && len(rr.buf)-rr.off < len(p)) && (rr.gone() == nil) {
+		if until.IsZero() && len(rr.buf) > rr.off {
+			until = time.Now().Add(r.delay)
+		}
+		if !until.IsZero() && !time.Now().Before(until) {
+			break
+		}
+		rr.wait(until)
+	}
+
+	if err := rr.ready(time.Time{}); err != nil {
+		return 0, err
+	}
+
+	n = copy(p, rr.buf[rr.off:])
+	rr.off += n
+	rr.progressed()
+
+	return n, nil
+}
+
+// Close releases the reader from the buffer.
+func (r *batchReader) Close() error {
+	return r.src.Close()
+}
+
+func (r *batchReader) self() *reader {
+	return r.src
+}
@@ -0,0 +1,47 @@
+package buffer
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pxi/is"
+)
+
+func TestBatchReader(t *testing.T) {
+	b := &Buffer{}
+	r := NewBatchReader(b, 4, time.Hour)
+
+	// Small writes are collected until maxBytes.
+	go func() {
+		for _, w := range []string{"a", "b", "c", "d", "e"} {
+			time.Sleep(time.Millisecond)
+			write(b, w)
+		}
+	}()
+	s, err := read(r, 16)
+	is.Ok(t, err)
+	is.Equal(t, s, "abcd")
+
+	// EOF flushes the partial batch.
+	time.Sleep(10 * time.Millisecond)
+	is.Ok(t, b.Close())
+	s, err = read(r, 16)
+	is.Ok(t, err)
+	is.Equal(t, s, "e")
+	_, err = read(r, 16)
+	is.Equal(t, err, io.EOF)
+
+	// The delay bounds the wait for a full batch.
+	b.Reset()
+	r = NewBatchReader(b, 4, 5*time.Millisecond)
+	is.Ok(t, write(b, w1))
+	s, err = read(r, 16)
+	is.Ok(t, err)
+	is.Equal(t, s, w1)
+
+	// Batching can not be bypassed.
+	_, ok := r.(io.ReaderAt)
+	is.Equal(t, ok, false)
+	is.Ok(t, r.(io.Closer).Close())
+}
@@ -0,0 +1,75 @@
+package buffer
+
+import (
+	"bytes"
+	"io"
+)
+
+var boms = [][]byte{
+	{0xef, 0xbb, 0xbf}, // UTF-8
+	{0xfe, 0xff},       // UTF-16 big endian
+	{0xff, 0xfe},       // UTF-16 little endian
+}
+
+type bomStripReader struct {
+	src  *reader
+	head []byte
+	done bool
+}
+
+// NewBOMStripReader returns a new io.Reader that emits b without a leading
+// UTF-8 or UTF-16 byte order mark. Nothing is emitted until enough bytes are
+// written to tell whether b starts with one. The reader also implements
+// io.Closer.
+func NewBOMStripReader(b *Buffer) io.Reader {
+	return &bomStripReader{src: NewReader(b).(*reader)}
+}
+
+func (r *bomStripReader) Read(p []byte) (int, error) {
+	if !r.done {
+		if err := r.detect(); err != nil {
+			return 0, err
+		}
+	}
+
+	// Deliver what was read ahead for detection first.
+	if len(r.head) > 0 {
+		n := copy(p, r.head)
+		r.head = r.head[n:]
+		return n, nil
+	}
+	return r.src.Read(p)
+}
+
+// detect reads ahead enough of src to strip a byte order mark, if any.
+func (r *bomStripReader) detect() error {
+	var head [3]byte
+	for len(r.head) < len(head) {
+		n, err := r.src.Read(head[:len(head)-len(r.head)])
+		r.head = append(r.head, head[:n]...)
+		if isEnd(err) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, bom := range boms {
+		if bytes.HasPrefix(r.head, bom) {
+			r.head = r.head[len(bom):]
+			break
+		}
+	}
+	r.done = true
+	return nil
+}
+
+// Close releases the reader from the buffer.
+func (r *bomStripReader) Close() error {
+	return r.src.Close()
+}
+
+func (r *bomStripReader) self() *reader {
+	return r.src
+}
@@ -0,0 +1,63 @@
+package buffer
+
+import (
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/pxi/is"
+)
+
+func TestBOMStripReader(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"\xef\xbb\xbfabc", "abc"},
+		{"\xfe\xffab", "ab"},
+		{"\xff\xfeab", "ab"},
+		{"\xefabc", "\xefabc"},
+		{"a", "a"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		b := &Buffer{}
+		r := NewBOMStripReader(b)
+		for i := 0; i < len(tt.in); i++ {
+			is.Ok(t, write(b, tt.in[i:i+1]))
+		}
+		is.Ok(t, b.Close())
+
+		p, err := io.ReadAll(r)
+		is.Ok(t, err)
+		is.Equal(t, string(p), tt.want)
+	}
+
+	// A mark past the start is kept, however small the reads.
+	b := &Buffer{}
+	r := NewBOMStripReader(b)
+	is.Ok(t, write(b, "a\xfe\xffbcd"))
+	is.Ok(t, b.Close())
+	p, err := io.ReadAll(iotest.OneByteReader(r))
+	is.Ok(t, err)
+	is.Equal(t, string(p), "a\xfe\xffbcd")
+
+	// The end of the message delivers a short start.
+	b = &Buffer{}
+	r = NewBOMStripReader(b)
+	is.Ok(t, write(b, "a"))
+	b.EndMessage()
+	s, err := read(r, 8)
+	is.Ok(t, err)
+	is.Equal(t, s, "a")
+	_, err = read(r, 8)
+	is.Equal(t, err, ErrEndOfMessage)
+
+	// Reset is reported while detecting.
+	b = &Buffer{}
+	r = NewBOMStripReader(b)
+	is.Ok(t, write(b, "\xef"))
+	b.Reset()
+	_, err = read(r, 8)
+	is.Equal(t, err, io.ErrUnexpectedEOF)
+}
@@ -3,18 +3,74 @@
 package buffer
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"errors"
+	"hash"
 	"io"
+	"os"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Buffer is a variable-sized buffer of bytes.
+//
+// A Write that has returned happens before any Read that observes the written
+// bytes, in the sense of the Go memory model. Callers may rely on this to
+// publish data written before the bytes themselves.
 type Buffer struct {
 	mu  sync.RWMutex
 	buf []byte
 	eof bool
-	set bool
-	sig *sync.Cond
+	eom bool
+	gen uint64
+	sig chan struct{}
+
+	rs        map[*reader]struct{}
+	anchors   int
+	creditors int
+	leakN     int
+	leakF     func(count int)
+	leak      bool
+
+	meta map[string]any
+
+	reallocs int
+	grown    int
+	peak     int
+
+	framed bool
+	ends   []int
+
+	batch int
+	unsig int
+
+	sum  *[sha256.Size]byte
+	hash hash.Hash
+
+	taken bool
+	tx    chan struct{}
+
+	resetting int
+
+	keep bool
+	prev []byte
+
+	backlogs [2]backlog
+
+	snapTTL time.Duration
+	snapAt  time.Time
+	snapB   []byte
+	snapS   string
+	snapOK  bool
+
+	pmu   sync.Mutex
+	prog  chan struct{}
+	pwant atomic.Bool
+
+	ls []listener
 }
 
 // Len returns the number of bytes written to buffer.
@@ -24,6 +80,21 @@ func (b *Buffer) Len() int {
 	return len(b.buf)
 }
 
+// PeakLen returns the largest length the buffer has had. It is kept across
+// Reset so it covers the whole lifetime of the buffer.
+func (b *Buffer) PeakLen() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.peak
+}
+
+// Generation returns the number of times the buffer has been reset.
+func (b *Buffer) Generation() uint64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.gen
+}
+
 // Cap returns the capacity allocated for the buffer.
 func (b *Buffer) Cap() int {
 	b.mu.RLock()
@@ -31,23 +102,177 @@ func (b *Buffer) Cap() int {
 	return cap(b.buf)
 }
 
-// Bytes returns a copy of the underlying buffer.
+// Bytes returns a copy of the underlying buffer. With a snapshot cache the
+// copy is shared between callers and must not be modified.
 func (b *Buffer) Bytes() []byte {
 	b.mu.RLock()
-	defer b.mu.RUnlock()
-	return append([]byte(nil), b.buf...)
+	if b.snapTTL <= 0 {
+		defer b.mu.RUnlock()
+		return append([]byte(nil), b.buf...)
+	}
+	b.mu.RUnlock()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expire()
+	if b.snapB == nil {
+		b.snapB = append([]byte(nil), b.buf...)
+		b.snapB = b.snapB[:len(b.snapB):len(b.snapB)]
+	}
+	return b.snapB
 }
 
 // String returns a copy of the underlying buffer as a string.
 func (b *Buffer) String() string {
+	b.mu.RLock()
+	if b.snapTTL <= 0 {
+		defer b.mu.RUnlock()
+		return string(b.buf)
+	}
+	b.mu.RUnlock()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expire()
+	if !b.snapOK {
+		b.snapS = string(b.buf)
+		b.snapOK = true
+	}
+	return b.snapS
+}
+
+// SetSnapshotCache makes Bytes and String share one copy of the contents
+// between calls for up to ttl, until the next change to the buffer. The
+// cached string is immutable as any string, but the cached slice returned
+// by Bytes is shared and must not be modified. A ttl of zero or less turns
+// the cache off, which is the default.
+func (b *Buffer) SetSnapshotCache(ttl time.Duration) {
+	b.mu.Lock()
+	b.snapTTL = ttl
+	b.invalidate()
+	b.mu.Unlock()
+}
+
+// expire drops cached snapshots older than their ttl and notes the time a
+// new one is taken. Must be called with the write lock held.
+func (b *Buffer) expire() {
+	now := time.Now()
+	if now.Sub(b.snapAt) >= b.snapTTL {
+		b.invalidate()
+	}
+	if b.snapB == nil && !b.snapOK {
+		b.snapAt = now
+	}
+}
+
+// invalidate drops values cached from the buffer contents. Must be called
+// with the write lock held.
+func (b *Buffer) invalidate() {
+	b.sum = nil
+	b.snapB = nil
+	b.snapS = ""
+	b.snapOK = false
+}
+
+// SetMeta associates val with key in the buffer metadata. Metadata describes
+// the current contents and is cleared by Reset.
+func (b *Buffer) SetMeta(key string, val any) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.meta == nil {
+		b.meta = make(map[string]any)
+	}
+	b.meta[key] = val
+}
+
+// Meta returns the metadata value associated with key.
+func (b *Buffer) Meta(key string) (any, bool) {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
-	return string(b.buf)
+	val, ok := b.meta[key]
+	return val, ok
+}
+
+// AllocStats returns the number of times the underlying buffer was
+// reallocated to grow and the total capacity gained by doing so. The initial
+// allocation is not counted. Stats are kept across Reset.
+func (b *Buffer) AllocStats() (reallocs int, grownBytes int) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.reallocs, b.grown
+}
+
+// Fingerprint returns the SHA-256 digest of the buffer contents. It blocks
+// until the buffer is closed. The digest is computed once per generation.
+func (b *Buffer) Fingerprint() [sha256.Size]byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for !b.eof {
+		b.await()
+	}
+	if b.sum == nil {
+		sum := sha256.Sum256(b.buf)
+		b.sum = &sum
+	}
+	return *b.sum
+}
+
+// ResetHash installs h to hash all subsequent writes and returns the sum of
+// the previously installed hash, or nil if there was none. Swapping happens
+// between writes, so every written byte is hashed by exactly one hash. A nil
+// h stops hashing. Reset does not affect hashing.
+func (b *Buffer) ResetHash(h hash.Hash) []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var sum []byte
+	if b.hash != nil {
+		sum = b.hash.Sum(nil)
+	}
+	b.hash = h
+	return sum
 }
 
 // errClosed is returned from Write if the buffer is closed.
 var errClosed = errors.New("buffer: write on closed buffer")
 
+// errTaken is returned from Write and TakeBytes if the bytes were taken.
+var errTaken = errors.New("buffer: use of buffer after TakeBytes")
+
+// errTakeOpen is returned from TakeBytes if the buffer is not closed.
+var errTakeOpen = errors.New("buffer: take bytes of open buffer")
+
+// errTakeReaders is returned from TakeBytes if the buffer has live readers.
+var errTakeReaders = errors.New("buffer: take bytes with live readers")
+
+// TakeBytes returns the underlying buffer without copying it. The buffer
+// must be closed and all its readers closed. The caller owns the returned
+// slice and the buffer must not be used afterwards; writes to it fail.
+// Taking the bytes ends the current generation and is reported as
+// EventReset, as a reset is.
+func (b *Buffer) TakeBytes() ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch {
+	case b.taken:
+		return nil, errTaken
+	case !b.eof:
+		return nil, errTakeOpen
+	case len(b.rs) > 0:
+		return nil, errTakeReaders
+	}
+
+	buf := b.buf
+	b.buf = nil
+	b.taken = true
+	b.invalidate()
+	b.gen++
+	b.signal()
+	b.notify(EventReset)
+	return buf, nil
+}
+
 // Write appends the contents of p to the buffer, growing it as needed.
 func (b *Buffer) Write(p []byte) (int, error) {
 	if len(p) == 0 {
@@ -57,19 +282,244 @@ func (b *Buffer) Write(p []byte) (int, error) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
+	if err := b.admit(len(p)); err != nil {
+		return 0, err
+	}
+
+	b.append(p)
+
+	return len(p), nil
+}
+
+// WriteIfRoom appends p only if the buffer length stays within max and
+// reports whether it did. It never waits: p is dropped if the buffer does not
+// accept writes or if writing would wait for an anchor reader, credits or
+// ResetWait.
+func (b *Buffer) WriteIfRoom(p []byte, max int) (int, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.buf)+len(p) > max || b.writable() != nil {
+		return 0, false
+	}
+	if len(p) == 0 {
+		return 0, true
+	}
+	if b.blocked(len(p)) {
+		return 0, false
+	}
+
+	b.append(p)
+	return len(p), true
+}
+
+// errTailRange is returned from UpdateTail if the tail is longer than the
+// buffer.
+var errTailRange = errors.New("buffer: tail out of range")
+
+// errTailRead is returned from UpdateTail if a reader has read into the
+// tail.
+var errTailRead = errors.New("buffer: tail already read")
+
+// UpdateTail calls fn with the last n bytes of the buffer to modify them in
+// place and signals readers. It fails if a live reader has already read any
+// of those bytes. This breaks the assumption that written bytes never
+// change and is meant only for small mutable trailers, such as a count,
+// that readers are known to read last. Bytes obtained with ReadAt or Bytes
+// are not accounted for.
+func (b *Buffer) UpdateTail(n int, fn func([]byte)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.writable(); err != nil {
+		return err
+	}
+	if n < 0 || n > len(b.buf) {
+		return errTailRange
+	}
+
+	start := len(b.buf) - n
+	for r := range b.rs {
+		if r.mark == b.gen && r.off > start {
+			return errTailRead
+		}
+	}
+
+	fn(b.buf[start:])
+	b.invalidate()
+	b.signal()
+	return nil
+}
+
+// ErrEndOfMessage is returned by readers that have read a message ended by
+// EndMessage.
+var ErrEndOfMessage = errors.New("buffer: end of message")
+
+// errEndOfMessage is returned from Write after EndMessage.
+var errEndOfMessage = errors.New("buffer: write after end of message")
+
+// EndMessage marks the contents of the buffer as a complete message. Readers
+// that have read it get ErrEndOfMessage instead of waiting for more, and
+// writes fail until Reset begins the next message. Unlike Close, which ends
+// the stream for good and takes precedence, EndMessage only ends the current
+// generation.
+func (b *Buffer) EndMessage() {
+	b.mu.Lock()
+	if !b.eom {
+		b.eom = true
+		b.signal()
+	}
+	b.mu.Unlock()
+}
+
+// ended returns io.EOF if the buffer is closed, ErrEndOfMessage if the
+// message has ended, and nil otherwise. Must be called with a lock held.
+func (b *Buffer) ended() error {
+	switch {
+	case b.eof:
+		return io.EOF
+	case b.eom:
+		return ErrEndOfMessage
+	}
+	return nil
+}
+
+// isEnd reports whether err is one returned by ended, so that no more data
+// follows.
+func isEnd(err error) bool {
+	return err == io.EOF || err == ErrEndOfMessage
+}
+
+// writable returns the error for a write to the buffer, if any. Must be
+// called with the write lock held.
+func (b *Buffer) writable() error {
+	if b.taken {
+		return errTaken
+	}
 	if b.eof {
-		return 0, errClosed
+		return errClosed
+	}
+	if b.eom {
+		return errEndOfMessage
+	}
+	return nil
+}
+
+// admit waits until n bytes can be written without getting ahead of an
+// anchor reader by more than its lead or exceeding the credit of a reader,
+// and while ResetWait is waiting, and returns the error for the write, if
+// any. Must be called with the write lock held.
+func (b *Buffer) admit(n int) error {
+	for {
+		if err := b.writable(); err != nil {
+			return err
+		}
+		if !b.blocked(n) {
+			return nil
+		}
+		b.awaitProgress()
+	}
+}
+
+// blocked reports whether a write of n bytes has to wait for ResetWait, an
+// anchor reader or credits. Must be called with the write lock held.
+func (b *Buffer) blocked(n int) bool {
+	return b.resetting > 0 || b.anchored(n) || b.uncredited(n)
+}
+
+// WouldBlock reports whether a Write of n bytes would wait for readers to
+// catch up, because of ResetWait, an anchor reader or credits. The answer is
+// advisory as readers and other writers may change it right away. A write
+// that would fail does not wait.
+func (b *Buffer) WouldBlock(n int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return n > 0 && b.writable() == nil && b.blocked(n)
+}
+
+// uncredited reports whether a reader of the current generation that grants
+// credits has less than n left. Must be called with the write lock held.
+func (b *Buffer) uncredited(n int) bool {
+	if b.creditors == 0 {
+		return false
+	}
+	for r := range b.rs {
+		if r.credited && r.mark == b.gen && r.credit < n {
+			return true
+		}
 	}
+	return false
+}
 
+// anchored reports whether writing n bytes would get ahead of an anchor
+// reader of the current generation by more than its lead. A reader that has
+// read everything never holds back the write. Must be called with the write
+// lock held.
+func (b *Buffer) anchored(n int) bool {
+	if b.anchors == 0 {
+		return false
+	}
+	for r := range b.rs {
+		if r.lead > 0 && r.mark == b.gen && r.off < len(b.buf) && len(b.buf)+n-r.off > r.lead {
+			return true
+		}
+	}
+	return false
+}
+
+// append appends p to the buffer and signals readers. Must be called with
+// the write lock held.
+func (b *Buffer) append(p []byte) {
 	if b.buf == nil {
 		// TODO(pxi) make initial cap configurable
 		b.buf = make([]byte, 0, 1024)
 	}
 
+	if b.hash != nil {
+		b.hash.Write(p)
+	}
+
+	b.invalidate()
+
+	c := cap(b.buf)
 	b.buf = append(b.buf, p...)
-	b.signal()
+	if cap(b.buf) != c {
+		b.reallocs++
+		b.grown += cap(b.buf) - c
+	}
+	if len(b.buf) > b.peak {
+		b.peak = len(b.buf)
+	}
+	if b.framed {
+		b.ends = append(b.ends, len(b.buf))
+	}
+	if b.creditors > 0 {
+		for r := range b.rs {
+			if r.credited && r.mark == b.gen {
+				r.credit -= len(p)
+			}
+		}
+	}
 
-	return len(p), nil
+	// Coalesce signals of batched writes.
+	b.unsig++
+	if b.unsig >= b.batch {
+		b.signal()
+	}
+}
+
+// SetSignalBatchSize makes writes wake waiting readers only once every n
+// writes, reducing wake-ups for many small writes. Close and Reset always
+// wake readers, so the last partial batch is delivered on Close. A Write
+// that fills the batch delivers all pending writes. An n of one or less
+// wakes readers on every write, which is the default.
+func (b *Buffer) SetSignalBatchSize(n int) {
+	b.mu.Lock()
+	b.batch = n
+	if b.unsig >= b.batch {
+		b.signal()
+	}
+	b.mu.Unlock()
 }
 
 // Close closes buffer from writing and signals EOF to all readers.
@@ -78,65 +528,886 @@ func (b *Buffer) Close() error {
 	if !b.eof {
 		b.eof = true
 		b.signal()
+		b.notify(EventClosed)
 	}
 	b.mu.Unlock()
 	return nil
 }
 
+// SetRetainPrevious sets whether Reset keeps the contents of the generation
+// it discards for DeltaFromPrevious. Retaining them takes a second
+// allocation, which is released when retention is turned off.
+func (b *Buffer) SetRetainPrevious(on bool) {
+	b.mu.Lock()
+	b.keep = on
+	if !on {
+		b.prev = nil
+	}
+	b.mu.Unlock()
+}
+
+// DeltaFromPrevious returns a copy of the contents of the previous
+// generation, as retained by SetRetainPrevious, and of the current one.
+func (b *Buffer) DeltaFromPrevious() (prev []byte, cur []byte) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return append([]byte(nil), b.prev...), append([]byte(nil), b.buf...)
+}
+
+// CloseAll closes all bufs. Every buffer is closed even if closing another
+// fails, and the errors are joined.
+func CloseAll(bufs ...*Buffer) error {
+	var errs []error
+	for _, b := range bufs {
+		errs = append(errs, b.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// ResetAll resets all bufs.
+func ResetAll(bufs ...*Buffer) {
+	for _, b := range bufs {
+		b.Reset()
+	}
+}
+
 // Reset resets the buffer retaining allocated space. Current readers return
-// unexpected EOF as the data stream is discontinued.
+// unexpected EOF as the data stream is discontinued. Metadata is cleared.
 func (b *Buffer) Reset() {
 	b.mu.Lock()
+	b.reset()
+	b.mu.Unlock()
+}
+
+// ResetWait is like Reset but first waits until every live reader of the
+// current generation has read up to the length of the buffer at the time of
+// the call. Writes wait meanwhile, so readers can catch up; they go to the
+// new generation once the buffer is reset. If ctx is done first the buffer
+// is not reset, writes resume and ctx.Err() is returned. ResetWait returns
+// without resetting again if the buffer is reset by another caller
+// meanwhile.
+func (b *Buffer) ResetWait(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.resetting++
+	gen, end := b.gen, len(b.buf)
+	for {
+		if b.gen != gen {
+			b.resume()
+			return nil
+		}
+		if b.slowest() >= end {
+			b.resetting--
+			b.reset()
+			return nil
+		}
+
+		if b.sig == nil {
+			b.sig = make(chan struct{})
+		}
+		sig, prog := b.sig, b.progress()
+		b.mu.Unlock()
+		select {
+		case <-prog:
+		case <-sig:
+		case <-ctx.Done():
+			b.mu.Lock()
+			b.resume()
+			return ctx.Err()
+		}
+		b.mu.Lock()
+	}
+}
+
+// resume lets writes held back by a ResetWait that gives up through. Must be
+// called with the write lock held.
+func (b *Buffer) resume() {
+	b.resetting--
+	b.signal()
+}
+
+// errDrainClosed is returned from WaitDrainBelow if the buffer is closed
+// before it drains.
+var errDrainClosed = errors.New("buffer: closed before draining")
+
+// WaitDrainBelow blocks until fewer than n bytes are left unread by the
+// slowest live reader of the current generation. It returns errDrainClosed
+// if the buffer is closed and io.ErrUnexpectedEOF if it is reset meanwhile.
+func (b *Buffer) WaitDrainBelow(n int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	gen := b.gen
+	for {
+		switch {
+		case b.gen != gen:
+			return io.ErrUnexpectedEOF
+		case len(b.buf)-b.slowest() < n:
+			return nil
+		case b.eof:
+			return errDrainClosed
+		}
+		b.awaitProgress()
+	}
+}
+
+// Barrier returns the current length of the buffer as a barrier offset for
+// WaitBarrier.
+func (b *Buffer) Barrier() int {
+	return b.Len()
+}
+
+// WaitBarrier blocks until every live reader of the current generation has
+// read at least off bytes. It returns io.ErrUnexpectedEOF if the buffer is
+// reset meanwhile.
+func (b *Buffer) WaitBarrier(off int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	gen := b.gen
+	for {
+		if b.gen != gen {
+			return io.ErrUnexpectedEOF
+		}
+		if b.slowest() >= off {
+			return nil
+		}
+		b.awaitProgress()
+	}
+}
+
+// slowest returns the offset of the slowest live reader of the current
+// generation, or the buffer length if there are none. Must be called with
+// the write lock held.
+func (b *Buffer) slowest() int {
+	off := len(b.buf)
+	for r := range b.rs {
+		if r.mark == b.gen && r.off < off {
+			off = r.off
+		}
+	}
+	return off
+}
+
+// reset resets the buffer. Must be called with the write lock held.
+func (b *Buffer) reset() {
 	b.eof = false
-	b.buf = b.buf[:0]
-	b.set = !b.set
+	b.eom = false
+	if b.keep {
+		b.prev, b.buf = b.buf, b.prev[:0]
+	} else {
+		b.buf = b.buf[:0]
+	}
+	b.ends = b.ends[:0]
+	b.meta = nil
+	b.invalidate()
+	b.gen++
+	// Readers of past generations only ever fail from now on, release them.
+	for r := range b.rs {
+		b.untrack(r)
+	}
 	b.signal()
+	b.notify(EventReset)
+}
+
+// SetReaderLeakThreshold arranges for fn to be called with the live reader
+// count once it exceeds n. A reader is live from NewReader until it is
+// closed, reads to the end of b or b is reset. fn is called once per
+// crossing and is rearmed only after the count drops back to n/2 or below. A
+// nil fn disables the check.
+func (b *Buffer) SetReaderLeakThreshold(n int, fn func(count int)) {
+	b.mu.Lock()
+	b.leakN = n
+	b.leakF = fn
+	b.leak = false
+	b.mu.Unlock()
+}
+
+// track registers r as live and reports the readers count if it crossed the
+// leak threshold. Must be called with the write lock held.
+func (b *Buffer) track(r *reader) (int, bool) {
+	if b.rs == nil {
+		b.rs = make(map[*reader]struct{})
+	}
+	b.rs[r] = struct{}{}
+	n := len(b.rs)
+	if b.leakF == nil || b.leak || n <= b.leakN {
+		return n, false
+	}
+	b.leak = true
+	return n, true
+}
+
+// untrack removes r from live readers. Must be called with the write lock
+// held.
+func (b *Buffer) untrack(r *reader) {
+	if _, ok := b.rs[r]; ok {
+		if r.lead > 0 {
+			b.anchors--
+		}
+		if r.credited {
+			b.creditors--
+		}
+	}
+	delete(b.rs, r)
+	if b.leak && len(b.rs) <= b.leakN/2 {
+		b.leak = false
+	}
+}
+
+// await releases the write lock until the buffer signals. Must be called
+// with the write lock held.
+func (b *Buffer) await() {
+	if b.sig == nil {
+		b.sig = make(chan struct{})
+	}
+	sig := b.sig
+	b.mu.Unlock()
+	<-sig
+	b.mu.Lock()
+}
+
+// Event is a buffer lifecycle event.
+type Event int
+
+const (
+	// EventClosed is reported when the buffer is closed.
+	EventClosed Event = iota + 1
+
+	// EventReset is reported when the buffer is reset.
+	EventReset
+)
+
+// WaitEvent blocks until the buffer is next closed or reset and reports
+// which happened. A buffer that is already closed is waited on until reset.
+// If the buffer is closed and reset before WaitEvent observes it, only the
+// reset is reported.
+func (b *Buffer) WaitEvent() Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.nextEvent(b.gen, b.eof)
+}
+
+// NextEvent is like WaitEvent but delivers the event on the returned
+// channel. No goroutine is started to wait for the event. The buffer holds
+// on to the channel until the event, even if it is abandoned, so callers
+// polling for events should reuse a channel rather than ask for new ones.
+func (b *Buffer) NextEvent() <-chan Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	l := listener{c: make(chan Event, 1), eof: b.eof}
+	b.ls = append(b.ls, l)
+	return l.c
+}
+
+// listener is a channel returned from NextEvent and whether the buffer was
+// closed at the time.
+type listener struct {
+	c   chan Event
+	eof bool
+}
+
+// notify delivers ev to listeners waiting for it. Must be called with the
+// write lock held.
+func (b *Buffer) notify(ev Event) {
+	ls := b.ls[:0]
+	for _, l := range b.ls {
+		if ev == EventClosed && l.eof {
+			ls = append(ls, l)
+			continue
+		}
+		l.c <- ev
+	}
+	clear(b.ls[len(ls):])
+	b.ls = ls
+}
+
+// nextEvent waits for the first event after the state given by gen and eof.
+// Must be called with the write lock held.
+func (b *Buffer) nextEvent(gen uint64, eof bool) Event {
+	for {
+		if b.gen != gen {
+			return EventReset
+		}
+		if b.eof && !eof {
+			return EventClosed
+		}
+		b.await()
+	}
+}
+
+// progress returns a channel that is closed once a reader next advances.
+func (b *Buffer) progress() <-chan struct{} {
+	b.pmu.Lock()
+	defer b.pmu.Unlock()
+	if b.prog == nil {
+		b.prog = make(chan struct{})
+		b.pwant.Store(true)
+	}
+	return b.prog
+}
+
+// progressed wakes goroutines waiting for readers to advance. It is called
+// on every read, so it only takes the lock if there is a waiter.
+func (b *Buffer) progressed() {
+	if !b.pwant.Load() {
+		return
+	}
+	b.pmu.Lock()
+	if b.prog != nil {
+		close(b.prog)
+		b.prog = nil
+		b.pwant.Store(false)
+	}
+	b.pmu.Unlock()
+}
+
+// awaitProgress releases the write lock until a reader advances or the
+// buffer signals. Must be called with the write lock held.
+func (b *Buffer) awaitProgress() {
+	if b.sig == nil {
+		b.sig = make(chan struct{})
+	}
+	sig := b.sig
+
+	// Readers advance holding the read lock, so none can be missed here.
+	prog := b.progress()
 	b.mu.Unlock()
+	defer b.mu.Lock()
+
+	select {
+	case <-prog:
+	case <-sig:
+	}
 }
 
+// signal wakes all waiting readers. Must be called with the write lock held.
 func (b *Buffer) signal() {
+	b.unsig = 0
 	if b.sig != nil {
-		b.sig.Broadcast()
+		close(b.sig)
+		b.sig = make(chan struct{})
 	}
 }
 
 type reader struct {
 	*Buffer
+	base int
 	off  int
-	mark bool
+	ack  int
+	mark uint64
+	dl   time.Time
+	wake chan struct{}
+	lead int
+
+	closed bool
+
+	credit   int
+	credited bool
 }
 
-// NewReader returns a new io.Reader that will emit the whole b.
+// NewReader returns a new io.Reader that will emit the whole b. The reader
+// also implements io.Closer; closing it releases it from b. The reader is
+// also released once a read reports the end of b, with io.EOF or
+// ErrEndOfMessage, and when b is reset, so a reader that is read to the end
+// need not be closed.
 func NewReader(b *Buffer) io.Reader {
 	b.mu.Lock()
-	defer b.mu.Unlock()
+	r, report := b.newReader()
+	b.mu.Unlock()
+
+	report()
+	return r
+}
+
+// ErrGenerationGone is returned from NewReaderGen if the buffer has been
+// reset past the requested generation.
+var ErrGenerationGone = errors.New("buffer: generation has been reset")
+
+// errGenerationAhead is returned from NewReaderGen if the requested
+// generation has not begun.
+var errGenerationAhead = errors.New("buffer: generation has not begun")
+
+// NewReaderGen is like NewReader but fails unless gen is the current
+// generation of b.
+func NewReaderGen(b *Buffer, gen uint64) (io.Reader, error) {
+	return newReaderAt(b, Checkpoint{gen: gen})
+}
+
+// errCheckpointRange is returned from NewReaderFromCheckpoint if the
+// checkpoint is past the end of the buffer.
+var errCheckpointRange = errors.New("buffer: checkpoint out of range")
+
+// Checkpoint is a position in a generation of a buffer.
+type Checkpoint struct {
+	gen uint64
+	off int
+}
+
+// Checkpoint returns the current end of the buffer as a checkpoint.
+func (b *Buffer) Checkpoint() Checkpoint {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return Checkpoint{gen: b.gen, off: len(b.buf)}
+}
+
+// NewReaderFromCheckpoint is like NewReader but the returned reader starts at
+// cp, which must be of the current generation of b. Offsets given to ReadAt
+// are relative to cp.
+func NewReaderFromCheckpoint(b *Buffer, cp Checkpoint) (io.Reader, error) {
+	return newReaderAt(b, cp)
+}
+
+func newReaderAt(b *Buffer, cp Checkpoint) (io.Reader, error) {
+	b.mu.Lock()
+	switch {
+	case cp.gen < b.gen:
+		b.mu.Unlock()
+		return nil, ErrGenerationGone
+	case cp.gen > b.gen:
+		b.mu.Unlock()
+		return nil, errGenerationAhead
+	case cp.off > len(b.buf):
+		b.mu.Unlock()
+		return nil, errCheckpointRange
+	}
+	r, report := b.newReader()
+	r.base, r.off, r.ack = cp.off, cp.off, cp.off
+	b.mu.Unlock()
+
+	report()
+	return r, nil
+}
+
+// newReader returns a new live reader of the current generation and a func
+// reporting a crossed leak threshold, to be called without the lock. Must be
+// called with the write lock held.
+func (b *Buffer) newReader() (*reader, func()) {
 	if b.sig == nil {
-		b.sig = sync.NewCond(b.mu.RLocker())
+		b.sig = make(chan struct{})
+	}
+	r := &reader{Buffer: b, mark: b.gen, wake: make(chan struct{})}
+	n, leak := b.track(r)
+	fn := b.leakF
+	return r, func() {
+		if leak {
+			fn(n)
+		}
+	}
+}
+
+// Pipe returns a new reader of b passed through stages in order, each
+// stage wrapping the reader returned by the previous one. The returned
+// reader also implements io.Closer; closing it releases the reader of b.
+func (b *Buffer) Pipe(stages ...func(io.Reader) io.Reader) io.Reader {
+	src := NewReader(b)
+	r := src
+	for _, stage := range stages {
+		r = stage(r)
+	}
+	return &pipeReader{Reader: r, src: src.(*reader)}
+}
+
+type pipeReader struct {
+	io.Reader
+	src *reader
+}
+
+func (r *pipeReader) Close() error {
+	return r.src.Close()
+}
+
+func (r *pipeReader) self() *reader {
+	return r.src
+}
+
+// NewAnchorReader is like NewReader but the returned reader holds back
+// writes that would get it behind by more than maxLead bytes. A write larger
+// than maxLead waits until the reader has read everything. Other readers are
+// not affected. Closing the reader lifts the constraint. A maxLead of zero or
+// less sets no constraint, as with NewReader.
+func (b *Buffer) NewAnchorReader(maxLead int) io.Reader {
+	b.mu.Lock()
+	r, report := b.newReader()
+	if maxLead > 0 {
+		r.lead = maxLead
+		b.anchors++
+	}
+	b.mu.Unlock()
+
+	report()
+	return r
+}
+
+// errForeignReader is returned from ReadableFor for readers not of the
+// buffer.
+var errForeignReader = errors.New("buffer: reader of another buffer")
+
+// ReadableFor returns the number of bytes r can read without waiting. r must
+// be a reader returned by this package for b. For readers that transform b,
+// such as NewNumberedLineReader, it is the number of bytes of b they can take
+// in; bytes a reader holds back itself are not counted. It returns
+// io.ErrUnexpectedEOF if b has been reset since r was made.
+func (b *Buffer) ReadableFor(r io.Reader) (int, error) {
+	s, ok := r.(interface{ self() *reader })
+	if !ok || s.self() == nil || s.self().Buffer != b {
+		return 0, errForeignReader
+	}
+	rr := s.self()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if rr.mark != b.gen {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return len(b.buf) - rr.off, nil
+}
+
+func (r *reader) self() *reader {
+	return r
+}
+
+// GrantCredits allows the writer n more bytes. Once a reader has granted
+// credits, writes wait until they fit in its remaining credit. As every
+// reader receives every byte, a write must fit in the credit of each reader
+// that grants credits. Closing the reader lifts the constraint.
+func (r *reader) GrantCredits(n int) {
+	r.mu.Lock()
+	if _, ok := r.rs[r]; ok && !r.credited {
+		r.credited = true
+		r.creditors++
 	}
-	return &reader{Buffer: b, mark: b.set}
+	r.credit += n
+	r.mu.Unlock()
+
+	// Wake a writer waiting for credits.
+	r.progressed()
+}
+
+// Close releases the reader from the buffer. It does not affect the buffer or
+// other readers. Reads fail once the reader is closed.
+func (r *reader) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	r.untrack(r)
+	r.awaken()
+	r.mu.Unlock()
+	r.progressed()
+	return nil
+}
+
+// drained releases the reader from the buffer if err reports the end of it,
+// as nothing is left to read. It must be called without the lock held.
+func (r *reader) drained(err error) {
+	if !isEnd(err) {
+		return
+	}
+	r.mu.Lock()
+	r.untrack(r)
+	r.mu.Unlock()
+	r.progressed()
 }
 
 func (r *reader) Read(p []byte) (int, error) {
+	return r.read(p, time.Time{})
+}
+
+// Stale reports whether the buffer has been reset since the reader was made,
+// so that the data it read is of a discarded generation.
+func (r *reader) Stale() bool {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
+	return r.mark != r.gen
+}
 
-	// Wait for more data or EOF or reset.
-	for (!r.eof && len(r.buf) == r.off) && (r.mark == r.set) {
-		r.sig.Wait()
+// Ack acknowledges the first upTo bytes of the stream as processed. The
+// acknowledged offset never moves backwards nor past the bytes read. Ack may
+// be called concurrently with Read.
+func (r *reader) Ack(upTo int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if upTo > r.off {
+		upTo = r.off
 	}
+	if upTo > r.ack {
+		r.ack = upTo
+	}
+}
 
-	// Return unexpected eof if buffer was reset.
-	if r.mark != r.set {
-		return 0, io.ErrUnexpectedEOF
+// Reattach rewinds the reader to the last acknowledged offset so that bytes
+// read but not acknowledged are delivered again.
+func (r *reader) Reattach() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.off = r.ack
+}
+
+// SetReadDeadline sets the deadline for Read. A Read that waits for data
+// past t fails with os.ErrDeadlineExceeded; data that is already available
+// is still returned. A zero t means no deadline. Setting the deadline wakes
+// a Read of the reader that is waiting.
+func (r *reader) SetReadDeadline(t time.Time) error {
+	r.mu.Lock()
+	r.dl = t
+	r.awaken()
+	r.mu.Unlock()
+	return nil
+}
+
+// awaken wakes the reader only, if it is waiting. Must be called with the
+// write lock held.
+func (r *reader) awaken() {
+	close(r.wake)
+	r.wake = make(chan struct{})
+}
+
+// SetWriteDeadline does nothing as readers are not written to. It exists so
+// that the reader satisfies the deadline interfaces of net.Conn.
+func (r *reader) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+// SetDeadline is the same as SetReadDeadline.
+func (r *reader) SetDeadline(t time.Time) error {
+	return r.SetReadDeadline(t)
+}
+
+// errTimeout is returned from read if deadline passes while waiting.
+var errTimeout = errors.New("buffer: wait timed out")
+
+// read is like Read but also gives up waiting for data at deadline, if set,
+// returning errTimeout. The read deadline is reported as on Read.
+func (r *reader) read(p []byte, deadline time.Time) (n int, err error) {
+	defer func() { r.drained(err) }()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if err := r.ready(deadline); err != nil {
+		return 0, err
 	}
 
-	// Return EOF if buffer reported EOF.
-	if len(r.buf) == r.off && r.eof {
-		return 0, io.EOF
+	n = copy(p, r.buf[r.off:])
+	r.off += n
+	r.progressed()
+
+	return n, nil
+}
+
+// ReadVec is like Read but fills the slices of ps in order.
+func (r *reader) ReadVec(ps ...[]byte) (n int, err error) {
+	defer func() { r.drained(err) }()
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if err := r.ready(time.Time{}); err != nil {
+		return 0, err
 	}
 
-	n := copy(p, r.buf[r.off:])
+	for _, p := range ps {
+		m := copy(p, r.buf[r.off+n:])
+		n += m
+		if m < len(p) {
+			break
+		}
+	}
 	r.off += n
+	r.progressed()
+
+	return n, nil
+}
+
+// PeekAvailable returns a copy of up to max unread bytes that are available
+// now without advancing the reader. A negative max is taken as zero. It
+// never waits and returns an empty slice if nothing is available, the reader
+// is closed or the buffer has been reset.
+func (r *reader) PeekAvailable(max int) []byte {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.gone() != nil {
+		return nil
+	}
+	if max < 0 {
+		max = 0
+	}
+	p := r.buf[r.off:]
+	if len(p) > max {
+		p = p[:max]
+	}
+	return append([]byte(nil), p...)
+}
+
+// SeekSync advances the reader to just past the next occurrence of marker,
+// waiting for more data until it is written. If the buffer ends without it
+// SeekSync returns io.EOF or ErrEndOfMessage and leaves the reader where it
+// was.
+func (r *reader) SeekSync(marker []byte) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	from := r.off
+	for {
+		if err := r.gone(); err != nil {
+			return err
+		}
+		if i := bytes.Index(r.buf[from:], marker); i >= 0 {
+			r.off = from + i + len(marker)
+			r.progressed()
+			return nil
+		}
+		if err := r.ended(); err != nil {
+			return err
+		}
+
+		// Keep a partial marker at the end for the next search.
+		if n := len(r.buf) - len(marker) + 1; n > from {
+			from = n
+		}
+		r.wait(time.Time{})
+	}
+}
+
+// ready waits until there is data to read, or returns the error to report
+// instead. Must be called with the read lock held.
+func (r *reader) ready(deadline time.Time) error {
+	// Wait for more data or EOF or reset or deadline.
+	for (r.ended() == nil && len(r.buf) == r.off) && (r.gone() == nil) {
+		now := time.Now()
+		if !r.dl.IsZero() && !now.Before(r.dl) {
+			return os.ErrDeadlineExceeded
+		}
+		if !deadline.IsZero() && !now.Before(deadline) {
+			return errTimeout
+		}
+		dl := r.dl
+		if dl.IsZero() || (!deadline.IsZero() && deadline.Before(dl)) {
+			dl = deadline
+		}
+		r.wait(dl)
+	}
+
+	// Return unexpected eof if buffer was reset.
+	if err := r.gone(); err != nil {
+		return err
+	}
+
+	// Return EOF if buffer reported EOF or end of message.
+	if len(r.buf) == r.off {
+		if err := r.ended(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// errReaderClosed is returned from reads of a closed reader.
+var errReaderClosed = errors.New("buffer: read on closed reader")
+
+// gone returns the error to report if the reader is closed or the buffer has
+// been reset since the reader was made. Must be called with the read lock
+// held.
+func (r *reader) gone() error {
+	switch {
+	case r.closed:
+		return errReaderClosed
+	case r.mark != r.gen:
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// errNegativeOffset is returned from ReadAt for negative offsets.
+var errNegativeOffset = errors.New("buffer: negative offset")
+
+// ReadAt reads len(p) bytes starting at offset off from where the reader
+// started. It blocks until the bytes are written, returning io.EOF if the
+// buffer is closed short of them. It does not affect the offset used by
+// Read.
+func (r *reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+
+	off += int64(r.base)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Wait for the whole range or EOF or reset.
+	end := off + int64(len(p))
+	for (r.ended() == nil && int64(len(r.buf)) < end) && (r.gone() == nil) {
+		r.wait(time.Time{})
+	}
+
+	// Return unexpected eof if buffer was reset.
+	if err := r.gone(); err != nil {
+		return 0, err
+	}
+
+	if off >= int64(len(r.buf)) {
+		return 0, r.ended()
+	}
+
+	n := copy(p, r.buf[off:])
+	if n < len(p) {
+		return n, r.ended()
+	}
 
 	return n, nil
 }
+
+// waitFor waits until c is closed. It returns early with the error a Read
+// would report if the reader is closed, the buffer is reset or there is
+// nothing left to read.
+func (r *reader) waitFor(c <-chan struct{}) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for {
+		if err := r.gone(); err != nil {
+			return err
+		}
+		if len(r.buf) == r.off {
+			if err := r.ended(); err != nil {
+				return err
+			}
+		}
+
+		sig, wake := r.sig, r.wake
+		r.mu.RUnlock()
+		select {
+		case <-c:
+			r.mu.RLock()
+			return nil
+		case <-sig:
+		case <-wake:
+		}
+		r.mu.RLock()
+	}
+}
+
+// wait releases the read lock until the buffer signals, the reader is woken
+// or deadline, if set, passes. Must be called with the read lock held.
+func (r *reader) wait(deadline time.Time) {
+	sig, wake := r.sig, r.wake
+	r.mu.RUnlock()
+	defer r.mu.RLock()
+
+	if deadline.IsZero() {
+		select {
+		case <-sig:
+		case <-wake:
+		}
+		return
+	}
+
+	t := time.NewTimer(time.Until(deadline))
+	defer t.Stop()
+
+	select {
+	case <-sig:
+	case <-wake:
+	case <-t.C:
+	}
+}
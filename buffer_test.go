@@ -1,7 +1,12 @@
 package buffer
 
 import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
 	"io"
+	"runtime"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -105,3 +110,923 @@ func read(r io.Reader, c int) (string, error) {
 	n, err := r.Read(p)
 	return string(p[:n]), err
 }
+
+func TestReaderLeakThreshold(t *testing.T) {
+	b := &Buffer{}
+
+	var counts []int
+	b.SetReaderLeakThreshold(2, func(count int) {
+		counts = append(counts, count)
+	})
+
+	var rs []io.Closer
+	open := func(n int) {
+		for i := 0; i < n; i++ {
+			rs = append(rs, NewReader(b).(io.Closer))
+		}
+	}
+	close := func(n int) {
+		for i := 0; i < n; i++ {
+			is.Ok(t, rs[0].Close())
+			rs = rs[1:]
+		}
+	}
+
+	open(2)
+	is.Equal(t, len(counts), 0)
+
+	// Crossing the threshold fires once.
+	open(2)
+	is.Equal(t, counts, []int{3})
+
+	// Dropping to the threshold does not rearm.
+	close(2)
+	open(1)
+	is.Equal(t, counts, []int{3})
+
+	// Dropping to half of the threshold rearms.
+	close(2)
+	open(2)
+	is.Equal(t, counts, []int{3, 3})
+}
+
+func TestResetReleasesReaders(t *testing.T) {
+	b := &Buffer{}
+	b.SetReaderLeakThreshold(10, func(count int) {
+		t.Errorf("leak reported with %d readers", count)
+	})
+
+	for i := 0; i < 1000; i++ {
+		NewReader(b)
+		b.Reset()
+	}
+	is.Equal(t, len(b.rs), 0)
+
+	r := b.NewAnchorReader(1)
+	b.Reset()
+	is.Ok(t, write(b, w1+w2))
+	is.Ok(t, r.(io.Closer).Close())
+	is.Equal(t, b.anchors, 0)
+}
+
+func TestDrainedReadersReleased(t *testing.T) {
+	b := &Buffer{}
+	b.SetReaderLeakThreshold(1, func(count int) {
+		t.Errorf("leak reported with %d readers", count)
+	})
+	is.Ok(t, write(b, "a,b\n"))
+	is.Ok(t, b.Close())
+
+	for i := 0; i < 10; i++ {
+		_, err := NewCSVReader(b).ReadAll()
+		is.Ok(t, err)
+	}
+	p, err := io.ReadAll(NewReader(b))
+	is.Ok(t, err)
+	is.Equal(t, string(p), "a,b\n")
+
+	_, err = b.TakeBytes()
+	is.Ok(t, err)
+}
+
+func TestWrappers(t *testing.T) {
+	ts := func([]byte) time.Time { return time.Time{} }
+	for name, open := range map[string]func(b *Buffer) io.Closer{
+		"lines":    func(b *Buffer) io.Closer { return NewNumberedLineReader(b, 1).(io.Closer) },
+		"bom":      func(b *Buffer) io.Closer { return NewBOMStripReader(b).(io.Closer) },
+		"fault":    func(b *Buffer) io.Closer { return NewFaultyReader(b, FaultConfig{}).(io.Closer) },
+		"progress": func(b *Buffer) io.Closer { return NewProgressReader(b, 0, func(_, _ int64) {}).(io.Closer) },
+		"window":   func(b *Buffer) io.Closer { return NewTimeWindowReader(b, time.Second, ts).(io.Closer) },
+		"replay":   func(b *Buffer) io.Closer { return NewReplaySpeedReader(b, 0, ts).(io.Closer) },
+		"failover": func(b *Buffer) io.Closer { return NewFailoverReader(b, nil).(io.Closer) },
+		"pipe":     func(b *Buffer) io.Closer { return b.Pipe().(io.Closer) },
+		"deadline": func(b *Buffer) io.Closer { return NewDeadlineReader(b, time.Now()).(io.Closer) },
+		"batch":    func(b *Buffer) io.Closer { return NewBatchReader(b, 0, 0).(io.Closer) },
+		"latency":  func(b *Buffer) io.Closer { return NewLatencyReader(b, 0).(io.Closer) },
+		"beat":     func(b *Buffer) io.Closer { return NewHeartbeatReader(b, 0, nil).(io.Closer) },
+		"reliable": func(b *Buffer) io.Closer { return b.NewReliableReader(1) },
+	} {
+		b := &Buffer{}
+		is.Ok(t, b.EnableFraming())
+		r := open(b)
+		is.Ok(t, write(b, w1))
+		n, err := b.ReadableFor(r.(io.Reader))
+		if err != nil || n != len(w1) {
+			t.Errorf("%s: readable %d, %v", name, n, err)
+		}
+		is.Ok(t, b.Close())
+		is.Ok(t, r.Close())
+		_, err = b.TakeBytes()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestWriteHappensBeforeRead(t *testing.T) {
+	b := &Buffer{}
+
+	// Data published by the writer before Write must be visible to any
+	// reader that observes the written bytes. Run with -race.
+	var shared [64]int
+	sentinel := make([]byte, len(shared))
+	for i := range sentinel {
+		sentinel[i] = byte(i)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		r := NewReader(b)
+		go func() {
+			defer wg.Done()
+			p, err := io.ReadAll(r)
+			is.Ok(t, err)
+			is.Equal(t, p, sentinel)
+			for i, v := range shared {
+				is.Equal(t, v, i)
+			}
+		}()
+	}
+
+	for i := range shared {
+		shared[i] = i
+	}
+	_, err := b.Write(sentinel)
+	is.Ok(t, err)
+	is.Ok(t, b.Close())
+
+	wg.Wait()
+}
+
+func TestMeta(t *testing.T) {
+	b := &Buffer{}
+
+	_, ok := b.Meta("etag")
+	is.Equal(t, ok, false)
+
+	b.SetMeta("etag", "x1")
+	val, ok := b.Meta("etag")
+	is.Equal(t, ok, true)
+	is.Equal(t, val, "x1")
+
+	// Reset clears metadata along with the contents.
+	b.Reset()
+	_, ok = b.Meta("etag")
+	is.Equal(t, ok, false)
+}
+
+func TestAllocStats(t *testing.T) {
+	b := &Buffer{}
+
+	is.Ok(t, write(b, w1))
+	reallocs, grown := b.AllocStats()
+	is.Equal(t, reallocs, 0)
+	is.Equal(t, grown, 0)
+
+	c := b.Cap()
+	_, err := b.Write(make([]byte, c))
+	is.Ok(t, err)
+	reallocs, grown = b.AllocStats()
+	is.Equal(t, reallocs, 1)
+	is.Equal(t, grown, b.Cap()-c)
+}
+
+func BenchmarkWrite(b *testing.B) {
+	p := make([]byte, 512)
+	for i := 0; i < b.N; i++ {
+		buf := &Buffer{}
+		for j := 0; j < 64; j++ {
+			buf.Write(p)
+		}
+		reallocs, _ := buf.AllocStats()
+		b.ReportMetric(float64(reallocs), "reallocs/op")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	b := &Buffer{}
+
+	done := make(chan [32]byte)
+	go func() { done <- b.Fingerprint() }()
+
+	is.Ok(t, write(b, w1+w2))
+	select {
+	case <-done:
+		t.Fatal("fingerprint of open buffer")
+	case <-time.After(time.Millisecond):
+	}
+
+	is.Ok(t, b.Close())
+	is.Equal(t, <-done, sha256.Sum256([]byte(w1+w2)))
+	is.Equal(t, b.Fingerprint(), sha256.Sum256([]byte(w1+w2)))
+}
+
+func TestAck(t *testing.T) {
+	b := &Buffer{}
+	r := NewReader(b).(*reader)
+
+	is.Ok(t, write(b, w1+w2+w3))
+	s, err := read(r, 4)
+	is.Ok(t, err)
+	is.Equal(t, s, w1+w2)
+
+	// Acknowledgement is capped at the bytes read.
+	r.Ack(len(w1))
+	r.Ack(100)
+	r.Ack(0)
+	is.Equal(t, r.ack, len(w1+w2))
+
+	r.Ack(len(w1))
+	s, err = read(r, 8)
+	is.Ok(t, err)
+	is.Equal(t, s, w3)
+
+	// Unacknowledged bytes are delivered again.
+	r.Reattach()
+	s, err = read(r, 8)
+	is.Ok(t, err)
+	is.Equal(t, s, w3)
+
+	// Acknowledging races no Read.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			r.Ack(i)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		is.Ok(t, write(b, "x"))
+		_, err = read(r, 1)
+		is.Ok(t, err)
+	}
+	<-done
+}
+
+func TestWaitEvent(t *testing.T) {
+	b := &Buffer{}
+
+	c := b.NextEvent()
+	is.Ok(t, b.Close())
+	is.Equal(t, <-c, EventClosed)
+
+	// A closed buffer waits for the next reset.
+	c = b.NextEvent()
+	done := make(chan Event)
+	go func() { done <- b.WaitEvent() }()
+	time.Sleep(time.Millisecond)
+	b.Reset()
+	is.Equal(t, <-c, EventReset)
+	is.Equal(t, <-done, EventReset)
+
+	// Successive resets of a reused buffer are observed.
+	c = b.NextEvent()
+	b.Reset()
+	is.Equal(t, <-c, EventReset)
+}
+
+func TestTakeBytes(t *testing.T) {
+	b := &Buffer{}
+	r := NewReader(b).(io.Closer)
+	is.Ok(t, write(b, w1))
+
+	_, err := b.TakeBytes()
+	is.Equal(t, err, errTakeOpen)
+
+	is.Ok(t, b.Close())
+	_, err = b.TakeBytes()
+	is.Equal(t, err, errTakeReaders)
+
+	cp := b.Checkpoint()
+	c := b.NextEvent()
+	is.Ok(t, r.Close())
+	p, err := b.TakeBytes()
+	is.Ok(t, err)
+	is.Equal(t, string(p), w1)
+
+	// Neither the closed reader nor the checkpoint reach the taken bytes.
+	_, err = read(r.(io.Reader), 8)
+	is.Equal(t, err, errReaderClosed)
+	is.Equal(t, len(r.(interface{ PeekAvailable(int) []byte }).PeekAvailable(8)), 0)
+	_, err = NewReaderFromCheckpoint(b, cp)
+	is.Equal(t, err, ErrGenerationGone)
+	is.Equal(t, <-c, EventReset)
+
+	// The buffer is unusable afterwards.
+	_, err = b.TakeBytes()
+	is.Equal(t, err, errTaken)
+	b.Reset()
+	is.Equal(t, write(b, w2), errTaken)
+}
+
+func TestReadAt(t *testing.T) {
+	b := &Buffer{}
+	r := NewReader(b).(io.ReaderAt)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p := make([]byte, 4)
+		n, err := r.ReadAt(p, 2)
+		is.Ok(t, err)
+		is.Equal(t, string(p[:n]), w2+w3)
+	}()
+
+	is.Ok(t, write(b, w1+w2))
+	time.Sleep(time.Millisecond)
+	is.Ok(t, write(b, w3))
+	<-done
+
+	is.Ok(t, b.Close())
+	p := make([]byte, 4)
+	n, err := r.ReadAt(p, 4)
+	is.Equal(t, err, io.EOF)
+	is.Equal(t, string(p[:n]), w3)
+
+	_, err = r.ReadAt(p, -1)
+	is.Equal(t, err, errNegativeOffset)
+
+	// Read is not affected.
+	s, err := read(r.(io.Reader), 8)
+	is.Ok(t, err)
+	is.Equal(t, s, w1+w2+w3)
+}
+
+func TestResetWait(t *testing.T) {
+	b := &Buffer{}
+	r1 := NewReader(b)
+	r2 := NewReader(b).(io.ReadCloser)
+	is.Ok(t, write(b, w1+w2))
+
+	// Waiting is cut short by the context.
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	is.Equal(t, b.ResetWait(ctx), context.DeadlineExceeded)
+	is.Equal(t, b.Len(), len(w1+w2))
+
+	done := make(chan error)
+	go func() { done <- b.ResetWait(context.Background()) }()
+
+	// Readers drain, or are closed, before the reset.
+	s, err := read(r1, 8)
+	is.Ok(t, err)
+	is.Equal(t, s, w1+w2)
+	time.Sleep(time.Millisecond)
+	is.Equal(t, b.Len(), len(w1+w2))
+	is.Ok(t, r2.Close())
+
+	is.Ok(t, <-done)
+	is.Equal(t, b.Len(), 0)
+	_, err = read(r1, 8)
+	is.Equal(t, err, io.ErrUnexpectedEOF)
+
+	// Writes wait for the reset and go to the new generation.
+	r1 = NewReader(b)
+	is.Ok(t, write(b, w1))
+	go func() { done <- b.ResetWait(context.Background()) }()
+	time.Sleep(time.Millisecond)
+	wrote := make(chan error)
+	go func() { wrote <- write(b, w2) }()
+	time.Sleep(time.Millisecond)
+	is.Equal(t, b.String(), w1)
+	s, err = read(r1, 8)
+	is.Ok(t, err)
+	is.Equal(t, s, w1)
+	is.Ok(t, <-done)
+	is.Ok(t, <-wrote)
+	is.Equal(t, b.String(), w2)
+
+	// A reset by another caller ends the wait.
+	r1 = NewReader(b)
+	go func() { done <- b.ResetWait(context.Background()) }()
+	time.Sleep(time.Millisecond)
+	b.Reset()
+	is.Ok(t, <-done)
+	is.Equal(t, b.resetting, 0)
+	is.Ok(t, r1.(io.Closer).Close())
+}
+
+func TestCloseAll(t *testing.T) {
+	b1, b2 := &Buffer{}, &Buffer{}
+	r1, r2 := NewReader(b1), NewReader(b2)
+
+	is.Ok(t, CloseAll(b1, b2))
+	testRead := func(r io.Reader, wantErr error) {
+		t.Helper()
+		_, err := read(r, 8)
+		is.Equal(t, err, wantErr)
+	}
+	testRead(r1, io.EOF)
+	testRead(r2, io.EOF)
+
+	ResetAll(b1, b2)
+	testRead(r1, io.ErrUnexpectedEOF)
+	testRead(r2, io.ErrUnexpectedEOF)
+}
+
+func TestPipe(t *testing.T) {
+	b := &Buffer{}
+	r := b.Pipe(
+		func(r io.Reader) io.Reader { return io.LimitReader(r, 4) },
+		func(r io.Reader) io.Reader { return io.MultiReader(r, strings.NewReader(w3)) },
+	)
+
+	is.Ok(t, write(b, w1))
+	s, err := read(r, 8)
+	is.Ok(t, err)
+	is.Equal(t, s, w1)
+
+	is.Ok(t, write(b, w2+w2))
+	is.Ok(t, b.Close())
+	p, err := io.ReadAll(r)
+	is.Ok(t, err)
+	is.Equal(t, string(p), w2+w3)
+
+	// Reset propagates through the stages.
+	b.Reset()
+	r = b.Pipe(func(r io.Reader) io.Reader { return io.LimitReader(r, 4) })
+	b.Reset()
+	_, err = read(r, 8)
+	is.Equal(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestPeakLen(t *testing.T) {
+	b := &Buffer{}
+	is.Ok(t, write(b, w1+w2))
+	b.Reset()
+	is.Ok(t, write(b, w3))
+	is.Equal(t, b.PeakLen(), len(w1+w2))
+}
+
+func TestWaitDrainBelow(t *testing.T) {
+	b := &Buffer{}
+	is.Ok(t, b.WaitDrainBelow(1))
+
+	r := NewReader(b)
+	is.Ok(t, write(b, w1+w2))
+
+	done := make(chan error)
+	go func() { done <- b.WaitDrainBelow(3) }()
+
+	// Reading one byte leaves too many unread.
+	_, err := read(r, 1)
+	is.Ok(t, err)
+	time.Sleep(time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("drained with 3 bytes unread")
+	default:
+	}
+
+	_, err = read(r, 1)
+	is.Ok(t, err)
+	is.Ok(t, <-done)
+
+	go func() { done <- b.WaitDrainBelow(1) }()
+	time.Sleep(time.Millisecond)
+	is.Ok(t, b.Close())
+	is.Equal(t, <-done, errDrainClosed)
+}
+
+func TestNewReaderGen(t *testing.T) {
+	b := &Buffer{}
+	gen := b.Generation()
+	is.Ok(t, write(b, w1))
+
+	r, err := NewReaderGen(b, gen)
+	is.Ok(t, err)
+	s, err := read(r, 8)
+	is.Ok(t, err)
+	is.Equal(t, s, w1)
+
+	_, err = NewReaderGen(b, gen+1)
+	is.Equal(t, err, errGenerationAhead)
+
+	b.Reset()
+	_, err = NewReaderGen(b, gen)
+	is.Equal(t, err, ErrGenerationGone)
+	is.Equal(t, b.Generation(), gen+1)
+}
+
+func TestAnchorReader(t *testing.T) {
+	b := &Buffer{}
+	r := b.NewAnchorReader(4).(io.ReadCloser)
+	NewReader(b)
+
+	is.Ok(t, write(b, w1+w2))
+
+	done := make(chan error)
+	go func() { done <- write(b, w3) }()
+	time.Sleep(time.Millisecond)
+	is.Equal(t, b.Len(), 4)
+
+	// Reading makes room for the write.
+	_, err := read(r, 2)
+	is.Ok(t, err)
+	is.Ok(t, <-done)
+	is.Equal(t, b.Len(), 6)
+
+	// Closing the anchor lifts the constraint.
+	go func() { done <- write(b, w1+w2+w3) }()
+	time.Sleep(time.Millisecond)
+	is.Equal(t, b.Len(), 6)
+	is.Ok(t, r.Close())
+	is.Ok(t, <-done)
+	is.Equal(t, b.Len(), 12)
+	is.Equal(t, b.anchors, 0)
+
+	// A lead of zero sets no constraint.
+	r = b.NewAnchorReader(0).(io.ReadCloser)
+	is.Ok(t, write(b, w1))
+	is.Ok(t, r.Close())
+	is.Equal(t, b.anchors, 0)
+}
+
+func TestWouldBlock(t *testing.T) {
+	b := &Buffer{}
+	r := b.NewAnchorReader(4)
+	is.Ok(t, write(b, w1+w2))
+	is.Equal(t, b.WouldBlock(0), false)
+	is.Equal(t, b.WouldBlock(1), true)
+
+	_, err := read(r, 2)
+	is.Ok(t, err)
+	is.Equal(t, b.WouldBlock(2), false)
+	is.Equal(t, b.WouldBlock(3), true)
+
+	// Failing writes do not wait.
+	is.Ok(t, b.Close())
+	is.Equal(t, b.WouldBlock(3), false)
+}
+
+func TestReadableFor(t *testing.T) {
+	b := &Buffer{}
+	r1, r2 := NewReader(b), NewRecordReader(b)
+	is.Ok(t, write(b, w1+w2))
+
+	_, err := read(r1, 1)
+	is.Ok(t, err)
+
+	n, err := b.ReadableFor(r1)
+	is.Ok(t, err)
+	is.Equal(t, n, 3)
+	n, err = b.ReadableFor(r2)
+	is.Ok(t, err)
+	is.Equal(t, n, 4)
+
+	_, err = b.ReadableFor(NewReader(&Buffer{}))
+	is.Equal(t, err, errForeignReader)
+	_, err = b.ReadableFor(strings.NewReader(w1))
+	is.Equal(t, err, errForeignReader)
+
+	b.Reset()
+	_, err = b.ReadableFor(r1)
+	is.Equal(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestUpdateTail(t *testing.T) {
+	b := &Buffer{}
+	r := NewReader(b)
+	is.Ok(t, write(b, w1+"0"))
+
+	is.Equal(t, b.UpdateTail(4, func([]byte) {}), errTailRange)
+	is.Ok(t, b.UpdateTail(1, func(p []byte) { p[0]++ }))
+	is.Equal(t, b.String(), w1+"1")
+
+	_, err := read(r, 8)
+	is.Ok(t, err)
+	is.Equal(t, b.UpdateTail(1, func([]byte) {}), errTailRead)
+}
+
+func TestDeltaFromPrevious(t *testing.T) {
+	b := &Buffer{}
+	b.SetRetainPrevious(true)
+
+	for _, w := range []string{w1, w2, w3} {
+		b.Reset()
+		is.Ok(t, write(b, w))
+	}
+	prev, cur := b.DeltaFromPrevious()
+	is.Equal(t, string(prev), w2)
+	is.Equal(t, string(cur), w3)
+
+	b.SetRetainPrevious(false)
+	b.Reset()
+	prev, _ = b.DeltaFromPrevious()
+	is.Equal(t, len(prev), 0)
+}
+
+func TestSignalBatchSize(t *testing.T) {
+	b := &Buffer{}
+	b.SetSignalBatchSize(3)
+	r := NewReader(b)
+
+	done := make(chan string)
+	go func() {
+		s, _ := read(r, 8)
+		done <- s
+	}()
+
+	// Readers wake on the third write.
+	time.Sleep(time.Millisecond)
+	is.Ok(t, write(b, "a"))
+	is.Ok(t, write(b, "b"))
+	time.Sleep(time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("reader woken before the batch was full")
+	default:
+	}
+	is.Ok(t, write(b, "c"))
+	is.Equal(t, <-done, "abc")
+
+	// Close delivers a partial batch.
+	go func() {
+		s, _ := read(r, 8)
+		done <- s
+	}()
+	time.Sleep(time.Millisecond)
+	is.Ok(t, write(b, "d"))
+	is.Ok(t, b.Close())
+	is.Equal(t, <-done, "d")
+}
+
+func TestReadVec(t *testing.T) {
+	b := &Buffer{}
+	r := NewReader(b).(interface {
+		ReadVec(ps ...[]byte) (int, error)
+	})
+	is.Ok(t, write(b, w1+w2+w3))
+
+	head, body := make([]byte, 2), make([]byte, 3)
+	n, err := r.ReadVec(head, body)
+	is.Ok(t, err)
+	is.Equal(t, n, 5)
+	is.Equal(t, string(head), w1)
+	is.Equal(t, string(body), w2+"c")
+
+	n, err = r.ReadVec(head, body)
+	is.Ok(t, err)
+	is.Equal(t, n, 1)
+	is.Equal(t, string(head[:1]), "c")
+
+	is.Ok(t, b.Close())
+	_, err = r.ReadVec(head)
+	is.Equal(t, err, io.EOF)
+}
+
+func TestStale(t *testing.T) {
+	b := &Buffer{}
+	r := NewReader(b).(interface{ Stale() bool })
+	is.Equal(t, r.Stale(), false)
+	b.Reset()
+	is.Equal(t, r.Stale(), true)
+}
+
+func TestWaitBarrier(t *testing.T) {
+	b := &Buffer{}
+	r1, r2 := NewReader(b), NewReader(b)
+	is.Ok(t, write(b, w1))
+	off := b.Barrier()
+	is.Ok(t, write(b, w2))
+
+	done := make(chan error)
+	go func() { done <- b.WaitBarrier(off) }()
+
+	_, err := read(r1, 8)
+	is.Ok(t, err)
+	time.Sleep(time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("barrier passed with a reader behind")
+	default:
+	}
+	_, err = read(r2, 1)
+	is.Ok(t, err)
+	_, err = read(r2, 1)
+	is.Ok(t, err)
+	is.Ok(t, <-done)
+
+	go func() { done <- b.WaitBarrier(b.Barrier() + 1) }()
+	time.Sleep(time.Millisecond)
+	b.Reset()
+	is.Equal(t, <-done, io.ErrUnexpectedEOF)
+}
+
+func TestCheckpoint(t *testing.T) {
+	b := &Buffer{}
+	is.Ok(t, write(b, w1))
+	cp := b.Checkpoint()
+	is.Ok(t, write(b, w2))
+
+	r, err := NewReaderFromCheckpoint(b, cp)
+	is.Ok(t, err)
+	s, err := read(r, 8)
+	is.Ok(t, err)
+	is.Equal(t, s, w2)
+
+	// ReadAt is relative to the checkpoint.
+	p := make([]byte, 1)
+	_, err = r.(io.ReaderAt).ReadAt(p, 1)
+	is.Ok(t, err)
+	is.Equal(t, string(p), "b")
+
+	_, err = NewReaderFromCheckpoint(b, Checkpoint{gen: cp.gen, off: b.Len() + 1})
+	is.Equal(t, err, errCheckpointRange)
+
+	b.Reset()
+	_, err = NewReaderFromCheckpoint(b, cp)
+	is.Equal(t, err, ErrGenerationGone)
+}
+
+func TestResetHash(t *testing.T) {
+	b := &Buffer{}
+	is.Equal(t, b.ResetHash(sha256.New()), []byte(nil))
+
+	is.Ok(t, write(b, w1))
+	sum := b.ResetHash(sha1.New())
+	want := sha256.Sum256([]byte(w1))
+	is.Equal(t, sum, want[:])
+
+	is.Ok(t, write(b, w2))
+	sum = b.ResetHash(nil)
+	want1 := sha1.Sum([]byte(w2))
+	is.Equal(t, sum, want1[:])
+}
+
+func TestEndMessage(t *testing.T) {
+	b := &Buffer{}
+	r := NewReader(b)
+
+	done := make(chan error)
+	go func() {
+		_, err := read(r, 8)
+		done <- err
+	}()
+	time.Sleep(time.Millisecond)
+	b.EndMessage()
+	is.Equal(t, <-done, ErrEndOfMessage)
+	is.Equal(t, write(b, w1), errEndOfMessage)
+
+	// The next message begins after reset.
+	b.Reset()
+	r = NewReader(b)
+	is.Ok(t, write(b, w1))
+	b.EndMessage()
+	s, err := read(r, 8)
+	is.Ok(t, err)
+	is.Equal(t, s, w1)
+	_, err = read(r, 8)
+	is.Equal(t, err, ErrEndOfMessage)
+
+	// Close takes precedence.
+	is.Ok(t, b.Close())
+	_, err = read(r, 8)
+	is.Equal(t, err, io.EOF)
+}
+
+func TestSeekSync(t *testing.T) {
+	b := &Buffer{}
+	r := NewReader(b).(interface {
+		io.Reader
+		SeekSync(marker []byte) error
+	})
+
+	done := make(chan error)
+	go func() { done <- r.SeekSync([]byte("SYNC")) }()
+
+	// The marker may be split across writes.
+	is.Ok(t, write(b, "garbageSY"))
+	time.Sleep(time.Millisecond)
+	is.Ok(t, write(b, "NC"+w1))
+	is.Ok(t, <-done)
+
+	s, err := read(r, 8)
+	is.Ok(t, err)
+	is.Equal(t, s, w1)
+
+	is.Ok(t, b.Close())
+	is.Equal(t, r.SeekSync([]byte("SYNC")), io.EOF)
+}
+
+func TestSnapshotCache(t *testing.T) {
+	b := &Buffer{}
+	b.SetSnapshotCache(time.Hour)
+	is.Ok(t, write(b, w1))
+
+	// Snapshots are shared until the next write.
+	p1, p2 := b.Bytes(), b.Bytes()
+	is.Equal(t, &p1[0], &p2[0])
+	is.Equal(t, b.String(), w1)
+
+	is.Ok(t, write(b, w2))
+	p3 := b.Bytes()
+	is.Equal(t, string(p3), w1+w2)
+	is.Content(t, &p1[0] != &p3[0], "snapshot not invalidated")
+	is.Equal(t, b.String(), w1+w2)
+
+	// Snapshots expire.
+	b.SetSnapshotCache(time.Nanosecond)
+	p1 = b.Bytes()
+	time.Sleep(time.Millisecond)
+	p2 = b.Bytes()
+	is.Content(t, &p1[0] != &p2[0], "snapshot not expired")
+}
+
+func TestGrantCredits(t *testing.T) {
+	b := &Buffer{}
+	r := NewReader(b).(interface {
+		io.ReadCloser
+		GrantCredits(n int)
+	})
+	NewReader(b)
+
+	r.GrantCredits(2)
+	is.Ok(t, write(b, w1))
+
+	done := make(chan error)
+	go func() { done <- write(b, w2) }()
+	time.Sleep(time.Millisecond)
+	is.Equal(t, b.Len(), 2)
+
+	// Credits are granted independently of reading.
+	r.GrantCredits(1)
+	time.Sleep(time.Millisecond)
+	is.Equal(t, b.Len(), 2)
+	r.GrantCredits(1)
+	is.Ok(t, <-done)
+	is.Equal(t, b.Len(), 4)
+
+	// Closing the reader lifts the constraint.
+	is.Ok(t, r.Close())
+	is.Ok(t, write(b, w3))
+}
+
+func TestPeekAvailable(t *testing.T) {
+	b := &Buffer{}
+	r := NewReader(b).(interface {
+		io.Reader
+		PeekAvailable(max int) []byte
+	})
+	is.Equal(t, len(r.PeekAvailable(8)), 0)
+
+	is.Ok(t, write(b, w1+w2))
+	is.Equal(t, string(r.PeekAvailable(3)), "aab")
+	is.Equal(t, string(r.PeekAvailable(8)), w1+w2)
+	is.Equal(t, len(r.PeekAvailable(-1)), 0)
+
+	s, err := read(r, 8)
+	is.Ok(t, err)
+	is.Equal(t, s, w1+w2)
+}
+
+func TestNoHelperGoroutines(t *testing.T) {
+	b := &Buffer{}
+	n := runtime.NumGoroutine()
+
+	// Reads waiting with deadlines and event listeners start no goroutines
+	// besides those of the readers.
+	const readers = 1000
+	var wg sync.WaitGroup
+	var cs []<-chan Event
+	for i := 0; i < readers; i++ {
+		r := NewReader(b).(interface {
+			io.Reader
+			SetReadDeadline(time.Time) error
+		})
+		is.Ok(t, r.SetReadDeadline(time.Now().Add(time.Hour)))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := read(r, 8)
+			is.Equal(t, err, io.EOF)
+		}()
+		cs = append(cs, b.NextEvent())
+	}
+	time.Sleep(10 * time.Millisecond)
+	is.Content(t, runtime.NumGoroutine() <= n+readers, "goroutines grow with readers")
+
+	is.Ok(t, b.Close())
+	wg.Wait()
+	for _, c := range cs {
+		is.Equal(t, <-c, EventClosed)
+	}
+}
+
+func TestWriteIfRoom(t *testing.T) {
+	b := &Buffer{}
+
+	n, ok := b.WriteIfRoom([]byte(w1+w2), 4)
+	is.Equal(t, n, 4)
+	is.Equal(t, ok, true)
+
+	n, ok = b.WriteIfRoom([]byte(w3), 4)
+	is.Equal(t, n, 0)
+	is.Equal(t, ok, false)
+	is.Equal(t, b.String(), w1+w2)
+
+	is.Ok(t, b.Close())
+	_, ok = b.WriteIfRoom([]byte(w3), 8)
+	is.Equal(t, ok, false)
+}
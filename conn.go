@@ -0,0 +1,72 @@
+package buffer
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+type addr struct{}
+
+func (addr) Network() string { return "buffer" }
+func (addr) String() string  { return "buffer" }
+
+type conn struct {
+	src    *reader
+	out    *Buffer
+	closed atomic.Bool
+}
+
+// ConnPair returns the two ends of an in-memory full duplex connection. b
+// carries the bytes written by client to server and a new buffer carries the
+// other direction. Unlike net.Pipe writes do not wait for the peer to read.
+// Closing an end closes the buffer it writes to, so the peer reads io.EOF.
+// More readers of either direction can be made with NewReader.
+//
+// Read deadlines behave as on a buffer reader. Writes never block, so write
+// deadlines are ignored.
+func (b *Buffer) ConnPair() (client, server net.Conn) {
+	back := &Buffer{}
+	client = &conn{src: NewReader(back).(*reader), out: b}
+	server = &conn{src: NewReader(b).(*reader), out: back}
+	return client, server
+}
+
+func (c *conn) Read(p []byte) (int, error) {
+	if c.closed.Load() {
+		return 0, net.ErrClosed
+	}
+	n, err := c.src.Read(p)
+	if err == errReaderClosed && c.closed.Load() {
+		return n, net.ErrClosed
+	}
+	return n, err
+}
+
+func (c *conn) Write(p []byte) (int, error) {
+	if c.closed.Load() {
+		return 0, net.ErrClosed
+	}
+	return c.out.Write(p)
+}
+
+func (c *conn) Close() error {
+	if c.closed.Swap(true) {
+		return net.ErrClosed
+	}
+	c.out.Close()
+
+	// Closing the reader wakes a Read that is waiting for data.
+	return c.src.Close()
+}
+
+func (c *conn) self() *reader {
+	return c.src
+}
+
+func (c *conn) SetDeadline(t time.Time) error      { return c.src.SetDeadline(t) }
+func (c *conn) SetReadDeadline(t time.Time) error  { return c.src.SetReadDeadline(t) }
+func (c *conn) SetWriteDeadline(t time.Time) error { return c.src.SetWriteDeadline(t) }
+
+func (c *conn) LocalAddr() net.Addr  { return addr{} }
+func (c *conn) RemoteAddr() net.Addr { return addr{} }
@@ -0,0 +1,45 @@
+package buffer
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pxi/is"
+)
+
+func TestConnPair(t *testing.T) {
+	b := &Buffer{}
+	client, server := b.ConnPair()
+
+	// Writes do not wait for the peer.
+	is.Ok(t, write(client, w1))
+	is.Ok(t, write(server, w2))
+
+	s, err := read(server, 8)
+	is.Ok(t, err)
+	is.Equal(t, s, w1)
+	s, err = read(client, 8)
+	is.Ok(t, err)
+	is.Equal(t, s, w2)
+
+	// Closing wakes a waiting Read.
+	done := make(chan error)
+	go func() {
+		_, err := read(client, 8)
+		done <- err
+	}()
+	time.Sleep(time.Millisecond)
+	is.Ok(t, client.Close())
+	is.Equal(t, <-done, net.ErrClosed)
+	is.Equal(t, write(client, w3), net.ErrClosed)
+
+	// Peer reads EOF.
+	_, err = read(server, 8)
+	is.Equal(t, err, io.EOF)
+
+	// The buffers are not reachable through the connection.
+	_, ok := server.(interface{ TakeBytes() ([]byte, error) })
+	is.Equal(t, ok, false)
+}
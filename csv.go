@@ -0,0 +1,12 @@
+package buffer
+
+import "encoding/csv"
+
+// NewCSVReader returns a csv.Reader that reads records from b. Reads block
+// until a whole record is written or b is closed, so a record is never cut
+// short by a momentarily drained buffer. Reset of b is reported as
+// io.ErrUnexpectedEOF. The underlying reader is released from b once the
+// records end.
+func NewCSVReader(b *Buffer) *csv.Reader {
+	return csv.NewReader(NewReader(b))
+}
@@ -0,0 +1,35 @@
+package buffer
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pxi/is"
+)
+
+func TestCSVReader(t *testing.T) {
+	b := &Buffer{}
+	r := NewCSVReader(b)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		rec, err := r.Read()
+		is.Ok(t, err)
+		is.Equal(t, rec, []string{"a", "b,c"})
+
+		_, err = r.Read()
+		is.Equal(t, err, io.EOF)
+	}()
+
+	// Write the record in pieces that split the quoted field.
+	is.Ok(t, write(b, `a,"b`))
+	time.Sleep(time.Millisecond)
+	is.Ok(t, write(b, `,c"`))
+	time.Sleep(time.Millisecond)
+	is.Ok(t, write(b, "\n"))
+	is.Ok(t, b.Close())
+
+	<-done
+}
@@ -0,0 +1,39 @@
+package buffer
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+type deadlineReader struct {
+	src      *reader
+	deadline time.Time
+}
+
+// NewDeadlineReader returns a new io.Reader that emits b until deadline.
+// Once deadline has passed every Read returns os.ErrDeadlineExceeded, even
+// if data is available. The reader also implements io.Closer.
+func NewDeadlineReader(b *Buffer, deadline time.Time) io.Reader {
+	return &deadlineReader{src: NewReader(b).(*reader), deadline: deadline}
+}
+
+func (r *deadlineReader) Read(p []byte) (int, error) {
+	if !time.Now().Before(r.deadline) {
+		return 0, os.ErrDeadlineExceeded
+	}
+	n, err := r.src.read(p, r.deadline)
+	if err == errTimeout {
+		err = os.ErrDeadlineExceeded
+	}
+	return n, err
+}
+
+// Close releases the reader from the buffer.
+func (r *deadlineReader) Close() error {
+	return r.src.Close()
+}
+
+func (r *deadlineReader) self() *reader {
+	return r.src
+}
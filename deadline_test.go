@@ -0,0 +1,90 @@
+package buffer
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pxi/is"
+)
+
+func TestDeadlineReader(t *testing.T) {
+	b := &Buffer{}
+	r := NewDeadlineReader(b, time.Now().Add(20*time.Millisecond))
+
+	is.Ok(t, write(b, w1))
+	s, err := read(r, 8)
+	is.Ok(t, err)
+	is.Equal(t, s, w1)
+
+	// Waiting for data is cut short by the deadline.
+	_, err = read(r, 8)
+	is.Equal(t, err, os.ErrDeadlineExceeded)
+
+	// Data written after the deadline is not returned.
+	is.Ok(t, write(b, w2))
+	_, err = read(r, 8)
+	is.Equal(t, err, os.ErrDeadlineExceeded)
+
+	// No way around the deadline is exposed.
+	_, ok := r.(io.ReaderAt)
+	is.Equal(t, ok, false)
+	is.Ok(t, r.(io.Closer).Close())
+}
+
+func TestSetReadDeadline(t *testing.T) {
+	b := &Buffer{}
+	r := NewReader(b).(interface {
+		io.Reader
+		SetDeadline(time.Time) error
+		SetReadDeadline(time.Time) error
+		SetWriteDeadline(time.Time) error
+	})
+
+	is.Ok(t, r.SetDeadline(time.Now().Add(time.Millisecond)))
+	_, err := read(r, 8)
+	is.Equal(t, err, os.ErrDeadlineExceeded)
+
+	// Available data is returned past the deadline.
+	is.Ok(t, write(b, w1))
+	s, err := read(r, 8)
+	is.Ok(t, err)
+	is.Equal(t, s, w1)
+
+	// Extending the deadline wakes a waiting Read.
+	is.Ok(t, r.SetReadDeadline(time.Now().Add(time.Hour)))
+	done := make(chan error)
+	go func() {
+		_, err := read(r, 8)
+		done <- err
+	}()
+	time.Sleep(time.Millisecond)
+	is.Ok(t, r.SetReadDeadline(time.Now()))
+	is.Equal(t, <-done, os.ErrDeadlineExceeded)
+
+	is.Ok(t, r.SetWriteDeadline(time.Now()))
+
+	// Other readers are not woken nor is a signal batch cut short.
+	b = &Buffer{}
+	b.SetSignalBatchSize(3)
+	r1 := NewReader(b)
+	r2 := NewReader(b).(interface{ SetReadDeadline(time.Time) error })
+	got := make(chan string)
+	go func() {
+		s, _ := read(r1, 8)
+		got <- s
+	}()
+	time.Sleep(time.Millisecond)
+	is.Ok(t, write(b, "a"))
+	is.Ok(t, r2.SetReadDeadline(time.Now().Add(time.Hour)))
+	is.Ok(t, write(b, "b"))
+	time.Sleep(time.Millisecond)
+	select {
+	case <-got:
+		t.Fatal("reader woken by the deadline of another")
+	default:
+	}
+	is.Ok(t, write(b, "c"))
+	is.Equal(t, <-got, "abc")
+}
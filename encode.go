@@ -0,0 +1,96 @@
+package buffer
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// chunkSize is the largest chunk written by Encode.
+const chunkSize = 32 << 10
+
+// errFormat is returned from Decode for malformed input.
+var errFormat = errors.New("buffer: malformed encoding")
+
+// Encode writes the contents of the buffer and whether it is closed to w.
+// The contents are written as a sequence of chunks, each prefixed by its
+// length as a 4 byte big endian integer, and terminated by a zero length
+// followed by a byte that is 1 if the buffer was closed and 0 otherwise.
+// Only bytes written before the call are encoded. Encode returns
+// io.ErrUnexpectedEOF if the buffer is reset meanwhile.
+func (b *Buffer) Encode(w io.Writer) error {
+	b.mu.Lock()
+	end, eof := len(b.buf), b.eof
+	r, report := b.newReader()
+	b.mu.Unlock()
+
+	report()
+	defer r.Close()
+
+	p := make([]byte, 4+chunkSize)
+	for r.off < end {
+		n := end - r.off
+		if n > chunkSize {
+			n = chunkSize
+		}
+		n, err := r.Read(p[4 : 4+n])
+		if err != nil {
+			return err
+		}
+		binary.BigEndian.PutUint32(p, uint32(n))
+		if _, err := w.Write(p[:4+n]); err != nil {
+			return err
+		}
+	}
+
+	binary.BigEndian.PutUint32(p, 0)
+	p[4] = 0
+	if eof {
+		p[4] = 1
+	}
+	_, err := w.Write(p[:5])
+	return err
+}
+
+// Decode reads a buffer encoded by Encode from r. It reads no further than
+// the end of the encoding.
+func Decode(r io.Reader) (*Buffer, error) {
+	b := &Buffer{}
+	p := make([]byte, chunkSize)
+	for {
+		if _, err := io.ReadFull(r, p[:4]); err != nil {
+			return nil, eof(err)
+		}
+		n := binary.BigEndian.Uint32(p)
+		if n > chunkSize {
+			return nil, errFormat
+		}
+		if n == 0 {
+			break
+		}
+		if _, err := io.ReadFull(r, p[:n]); err != nil {
+			return nil, eof(err)
+		}
+		b.Write(p[:n])
+	}
+
+	if _, err := io.ReadFull(r, p[:1]); err != nil {
+		return nil, eof(err)
+	}
+	switch p[0] {
+	case 0:
+	case 1:
+		b.Close()
+	default:
+		return nil, errFormat
+	}
+	return b, nil
+}
+
+// eof turns io.EOF in the middle of an encoding to io.ErrUnexpectedEOF.
+func eof(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,36 @@
+package buffer
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/pxi/is"
+)
+
+func TestEncode(t *testing.T) {
+	big := strings.Repeat("x", chunkSize+1)
+	for _, closed := range []bool{false, true} {
+		b := &Buffer{}
+		is.Ok(t, write(b, big))
+		if closed {
+			is.Ok(t, b.Close())
+		}
+
+		var enc bytes.Buffer
+		is.Ok(t, b.Encode(&enc))
+		enc.WriteString("tail")
+
+		d, err := Decode(&enc)
+		is.Ok(t, err)
+		is.Equal(t, d.String(), big)
+		is.Equal(t, write(d, w1) == errClosed, closed)
+		is.Equal(t, enc.String(), "tail")
+	}
+
+	_, err := Decode(strings.NewReader("\x00\x00"))
+	is.Equal(t, err, io.ErrUnexpectedEOF)
+	_, err = Decode(strings.NewReader("\x00\x00\x00\x00\x02"))
+	is.Equal(t, err, errFormat)
+}
@@ -0,0 +1,47 @@
+package buffer
+
+import "io"
+
+type failoverReader struct {
+	src  *reader
+	next func() *Buffer
+}
+
+// NewFailoverReader returns a new io.Reader that emits primary until it is
+// reset and then continues with the whole of the buffer returned by next,
+// failing over again on its reset. The reader returns io.EOF once next
+// returns nil. The reader also implements io.Closer.
+func NewFailoverReader(primary *Buffer, next func() *Buffer) io.Reader {
+	return &failoverReader{src: NewReader(primary).(*reader), next: next}
+}
+
+func (r *failoverReader) Read(p []byte) (int, error) {
+	for {
+		if r.src == nil {
+			return 0, io.EOF
+		}
+
+		n, err := r.src.Read(p)
+		if err != io.ErrUnexpectedEOF {
+			return n, err
+		}
+
+		r.src.Close()
+		r.src = nil
+		if b := r.next(); b != nil {
+			r.src = NewReader(b).(*reader)
+		}
+	}
+}
+
+// Close releases the reader from the current buffer.
+func (r *failoverReader) Close() error {
+	if r.src == nil {
+		return nil
+	}
+	return r.src.Close()
+}
+
+func (r *failoverReader) self() *reader {
+	return r.src
+}
@@ -0,0 +1,35 @@
+package buffer
+
+import (
+	"io"
+	"testing"
+
+	"github.com/pxi/is"
+)
+
+func TestFailoverReader(t *testing.T) {
+	b1, b2 := &Buffer{}, &Buffer{}
+	next := []*Buffer{b2, nil}
+	r := NewFailoverReader(b1, func() *Buffer {
+		b := next[0]
+		next = next[1:]
+		return b
+	})
+
+	is.Ok(t, write(b1, w1))
+	is.Ok(t, write(b2, w2))
+	s, err := read(r, 8)
+	is.Ok(t, err)
+	is.Equal(t, s, w1)
+
+	b1.Reset()
+	s, err = read(r, 8)
+	is.Ok(t, err)
+	is.Equal(t, s, w2)
+
+	b2.Reset()
+	_, err = read(r, 8)
+	is.Equal(t, err, io.EOF)
+	_, err = read(r, 8)
+	is.Equal(t, err, io.EOF)
+}
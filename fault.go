@@ -0,0 +1,70 @@
+package buffer
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrFault is the error injected by a faulty reader if FaultConfig.Err is
+// nil.
+var ErrFault = errors.New("buffer: injected fault")
+
+// FaultConfig configures the faults injected by a reader returned from
+// NewFaultyReader. The zero value injects no faults.
+type FaultConfig struct {
+	// Delay is slept before every Read.
+	Delay time.Duration
+
+	// MaxBytes limits the bytes returned by a single Read, if positive.
+	MaxBytes int
+
+	// ErrEvery makes every ErrEvery-th Read fail with Err without consuming
+	// data, if positive.
+	ErrEvery int
+
+	// Err is the injected error. ErrFault is used if nil.
+	Err error
+}
+
+type faultyReader struct {
+	src *reader
+	cfg FaultConfig
+	n   int
+}
+
+// NewFaultyReader returns a new io.Reader that emits the whole b while
+// injecting the faults described by cfg. Injected errors are transient and
+// all data is still delivered by later reads. The reader also implements
+// io.Closer.
+func NewFaultyReader(b *Buffer, cfg FaultConfig) io.Reader {
+	return &faultyReader{src: NewReader(b).(*reader), cfg: cfg}
+}
+
+func (r *faultyReader) Read(p []byte) (int, error) {
+	if r.cfg.Delay > 0 {
+		time.Sleep(r.cfg.Delay)
+	}
+
+	r.n++
+	if r.cfg.ErrEvery > 0 && r.n%r.cfg.ErrEvery == 0 {
+		if r.cfg.Err != nil {
+			return 0, r.cfg.Err
+		}
+		return 0, ErrFault
+	}
+
+	if r.cfg.MaxBytes > 0 && len(p) > r.cfg.MaxBytes {
+		p = p[:r.cfg.MaxBytes]
+	}
+	return r.src.Read(p)
+}
+
+// Close releases the reader from the buffer.
+func (r *faultyReader) Close() error {
+	return r.src.Close()
+}
+
+func (r *faultyReader) self() *reader {
+	return r.src
+}
@@ -0,0 +1,40 @@
+package buffer
+
+import (
+	"io"
+	"testing"
+
+	"github.com/pxi/is"
+)
+
+func TestFaultyReader(t *testing.T) {
+	b := &Buffer{}
+	is.Ok(t, write(b, w1+w2+w3))
+	is.Ok(t, b.Close())
+
+	r := NewFaultyReader(b, FaultConfig{MaxBytes: 1, ErrEvery: 3})
+
+	var got []byte
+	var faults int
+	p := make([]byte, 8)
+	for {
+		n, err := r.Read(p)
+		got = append(got, p[:n]...)
+		if err == ErrFault {
+			faults++
+			continue
+		}
+		if err == io.EOF {
+			break
+		}
+		is.Ok(t, err)
+		is.Equal(t, n, 1)
+	}
+	is.Equal(t, string(got), w1+w2+w3)
+	is.Equal(t, faults, 3)
+
+	// Without faults the reader is faithful.
+	p, err := io.ReadAll(NewFaultyReader(b, FaultConfig{}))
+	is.Ok(t, err)
+	is.Equal(t, string(p), w1+w2+w3)
+}
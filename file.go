@@ -0,0 +1,104 @@
+package buffer
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"time"
+)
+
+type file struct {
+	src    *reader
+	name   string
+	closed bool
+}
+
+// OpenFile returns a new reader of the whole b as an fs.File named name, for
+// serving b from an fs.FS. Reads wait for data as on any reader. Stat reports
+// the length of b at the time of the call, which is final once b is closed.
+// The file also implements io.Seeker; offsets are limited to the bytes
+// written so far and io.SeekEnd is relative to the length of b at the time
+// of the call.
+func (b *Buffer) OpenFile(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	return &file{src: NewReader(b).(*reader), name: name}, nil
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrClosed}
+	}
+	return f.src.Read(p)
+}
+
+// errWhence is returned from Seek for an invalid whence.
+var errWhence = errors.New("buffer: invalid whence")
+
+// errSeekRange is returned from Seek for offsets outside of the bytes
+// written.
+var errSeekRange = errors.New("buffer: seek out of range")
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrClosed}
+	}
+
+	r := f.src
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.gone(); err != nil {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: err}
+	}
+
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += int64(r.off - r.base)
+	case io.SeekEnd:
+		offset += int64(len(r.buf) - r.base)
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: errWhence}
+	}
+	if offset < 0 || offset > int64(len(r.buf)-r.base) {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: errSeekRange}
+	}
+
+	r.off = r.base + int(offset)
+	r.progressed()
+	return offset, nil
+}
+
+func (f *file) Stat() (fs.FileInfo, error) {
+	if f.closed {
+		return nil, &fs.PathError{Op: "stat", Path: f.name, Err: fs.ErrClosed}
+	}
+	return fileInfo{name: path.Base(f.name), size: int64(f.src.Len())}, nil
+}
+
+func (f *file) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	return f.src.Close()
+}
+
+func (f *file) self() *reader {
+	return f.src
+}
+
+type fileInfo struct {
+	name string
+	size int64
+}
+
+func (fi fileInfo) Name() string       { return fi.name }
+func (fi fileInfo) Size() int64        { return fi.size }
+func (fi fileInfo) Mode() fs.FileMode  { return 0o444 }
+func (fi fileInfo) ModTime() time.Time { return time.Time{} }
+func (fi fileInfo) IsDir() bool        { return false }
+func (fi fileInfo) Sys() any           { return nil }
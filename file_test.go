@@ -0,0 +1,99 @@
+package buffer
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pxi/is"
+)
+
+func TestOpenFile(t *testing.T) {
+	b := &Buffer{}
+	f, err := b.OpenFile("dir/data.bin")
+	is.Ok(t, err)
+
+	is.Ok(t, write(b, w1))
+	fi, err := f.Stat()
+	is.Ok(t, err)
+	is.Equal(t, fi.Name(), "data.bin")
+	is.Equal(t, fi.Size(), int64(2))
+
+	is.Ok(t, write(b, w2))
+	is.Ok(t, b.Close())
+	p, err := io.ReadAll(f)
+	is.Ok(t, err)
+	is.Equal(t, string(p), w1+w2)
+	fi, err = f.Stat()
+	is.Ok(t, err)
+	is.Equal(t, fi.Size(), int64(4))
+
+	is.Ok(t, f.Close())
+	_, err = f.Read(p)
+	is.Content(t, errors.Is(err, fs.ErrClosed), "read on closed file")
+
+	_, err = b.OpenFile("../x")
+	is.Content(t, errors.Is(err, fs.ErrInvalid), "invalid name accepted")
+}
+
+func TestOpenFileSeek(t *testing.T) {
+	b := &Buffer{}
+	f, err := b.OpenFile("data")
+	is.Ok(t, err)
+	is.Ok(t, write(b, w1+w2))
+	s := f.(io.ReadSeeker)
+
+	n, err := s.Seek(-1, io.SeekEnd)
+	is.Ok(t, err)
+	is.Equal(t, n, int64(3))
+	p, err := read(s, 8)
+	is.Ok(t, err)
+	is.Equal(t, p, "b")
+
+	n, err = s.Seek(1, io.SeekStart)
+	is.Ok(t, err)
+	is.Equal(t, n, int64(1))
+	p, err = read(s, 2)
+	is.Ok(t, err)
+	is.Equal(t, p, "ab")
+
+	_, err = s.Seek(3, io.SeekCurrent)
+	is.Content(t, errors.Is(err, errSeekRange), "seek past the end")
+
+	// The buffer itself is not exposed.
+	_, ok := f.(io.Writer)
+	is.Equal(t, ok, false)
+}
+
+type bufferFS map[string]*Buffer
+
+func (fsys bufferFS) Open(name string) (fs.File, error) {
+	b, ok := fsys[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return b.OpenFile(name)
+}
+
+func TestOpenFileServer(t *testing.T) {
+	b := &Buffer{}
+	is.Ok(t, write(b, w1+w2+w3))
+	is.Ok(t, b.Close())
+	srv := http.FileServerFS(bufferFS{"data": b})
+
+	// Content sniffing seeks back to the start.
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest("GET", "/data", nil))
+	is.Equal(t, w.Code, http.StatusOK)
+	is.Equal(t, w.Body.String(), w1+w2+w3)
+
+	req := httptest.NewRequest("GET", "/data", nil)
+	req.Header.Set("Range", "bytes=2-3")
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	is.Equal(t, w.Code, http.StatusPartialContent)
+	is.Equal(t, w.Body.String(), w2)
+}
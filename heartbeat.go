@@ -0,0 +1,57 @@
+package buffer
+
+import (
+	"io"
+	"time"
+)
+
+type heartbeatReader struct {
+	src      *reader
+	interval time.Duration
+	beat     []byte
+	out      []byte
+}
+
+// NewHeartbeatReader returns a new io.Reader that emits the whole b and
+// emits beat whenever no data has been available for interval. Heartbeats
+// stop at EOF. The reader also implements io.Closer and SetReadDeadline,
+// which fails a Read with os.ErrDeadlineExceeded as on a buffer reader.
+func NewHeartbeatReader(b *Buffer, interval time.Duration, beat []byte) io.Reader {
+	return &heartbeatReader{
+		src:      NewReader(b).(*reader),
+		interval: interval,
+		beat:     beat,
+	}
+}
+
+func (r *heartbeatReader) Read(p []byte) (int, error) {
+	// Finish a heartbeat that did not fit in the previous p.
+	if len(r.out) > 0 {
+		n := copy(p, r.out)
+		r.out = r.out[n:]
+		return n, nil
+	}
+
+	n, err := r.src.read(p, time.Now().Add(r.interval))
+	if err != errTimeout {
+		return n, err
+	}
+
+	n = copy(p, r.beat)
+	r.out = r.beat[n:]
+	return n, nil
+}
+
+// Close releases the reader from the buffer.
+func (r *heartbeatReader) Close() error {
+	return r.src.Close()
+}
+
+func (r *heartbeatReader) self() *reader {
+	return r.src
+}
+
+// SetReadDeadline sets the deadline for Read, as on a buffer reader.
+func (r *heartbeatReader) SetReadDeadline(t time.Time) error {
+	return r.src.SetReadDeadline(t)
+}
@@ -0,0 +1,47 @@
+package buffer
+
+import (
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pxi/is"
+)
+
+func TestHeartbeatReader(t *testing.T) {
+	b := &Buffer{}
+	r := NewHeartbeatReader(b, time.Millisecond, []byte("<3"))
+
+	// Idle reads return heartbeats, split if p is small.
+	s, err := read(r, 1)
+	is.Ok(t, err)
+	is.Equal(t, s, "<")
+	s, err = read(r, 8)
+	is.Ok(t, err)
+	is.Equal(t, s, "3")
+	s, err = read(r, 8)
+	is.Ok(t, err)
+	is.Equal(t, s, "<3")
+
+	// Data is returned as it arrives.
+	is.Ok(t, write(b, w1))
+	s, err = read(r, 8)
+	is.Ok(t, err)
+	is.Equal(t, s, w1)
+
+	// The read deadline is not taken for idleness.
+	d := r.(interface{ SetReadDeadline(time.Time) error })
+	is.Ok(t, d.SetReadDeadline(time.Now()))
+	_, err = read(r, 8)
+	is.Equal(t, err, os.ErrDeadlineExceeded)
+	is.Ok(t, d.SetReadDeadline(time.Time{}))
+
+	is.Ok(t, b.Close())
+	_, err = read(r, 8)
+	is.Equal(t, err, io.EOF)
+
+	_, ok := r.(io.ReaderAt)
+	is.Equal(t, ok, false)
+	is.Ok(t, r.(io.Closer).Close())
+}
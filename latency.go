@@ -0,0 +1,49 @@
+package buffer
+
+import (
+	"io"
+	"time"
+)
+
+type latencyReader struct {
+	src   *reader
+	delay time.Duration
+}
+
+// NewLatencyReader returns a new io.Reader that emits the whole b and
+// returns written data within maxDelay even if readers are woken less often,
+// as with SetSignalBatchSize. The reader also implements io.Closer.
+func NewLatencyReader(b *Buffer, maxDelay time.Duration) io.Reader {
+	return &latencyReader{src: NewReader(b).(*reader), delay: maxDelay}
+}
+
+func (r *latencyReader) Read(p []byte) (n int, err error) {
+	rr := r.src
+	defer func() { rr.drained(err) }()
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+
+	// Look for data at least every delay while waiting for a signal.
+	for (rr.ended() == nil && len(rr.buf) == rr.off) && (rr.gone() == nil) {
+		rr.wait(time.Now().Add(r.delay))
+	}
+
+	if err := rr.ready(time.Time{}); err != nil {
+		return 0, err
+	}
+
+	n = copy(p, rr.buf[rr.off:])
+	rr.off += n
+	rr.progressed()
+
+	return n, nil
+}
+
+// Close releases the reader from the buffer.
+func (r *latencyReader) Close() error {
+	return r.src.Close()
+}
+
+func (r *latencyReader) self() *reader {
+	return r.src
+}
@@ -0,0 +1,38 @@
+package buffer
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pxi/is"
+)
+
+func TestLatencyReader(t *testing.T) {
+	b := &Buffer{}
+	b.SetSignalBatchSize(100)
+	r := NewLatencyReader(b, time.Millisecond)
+
+	done := make(chan string)
+	go func() {
+		s, _ := read(r, 8)
+		done <- s
+	}()
+	time.Sleep(time.Millisecond)
+	is.Ok(t, write(b, w1))
+
+	select {
+	case s := <-done:
+		is.Equal(t, s, w1)
+	case <-time.After(time.Second):
+		t.Fatal("data held back by signal batching")
+	}
+
+	is.Ok(t, b.Close())
+	_, err := read(r, 8)
+	is.Equal(t, err, io.EOF)
+
+	_, ok := r.(io.ReaderAt)
+	is.Equal(t, ok, false)
+	is.Ok(t, r.(io.Closer).Close())
+}
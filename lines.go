@@ -0,0 +1,75 @@
+package buffer
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+)
+
+type numberedLineReader struct {
+	src  *reader
+	n    int
+	line []byte
+	out  []byte
+	err  error
+}
+
+// NewNumberedLineReader returns a new io.Reader that emits b line by line,
+// each prefixed with its line number and a tab. Numbering begins at start. A
+// final line without a newline is terminated with one at EOF or the end of
+// the message. The reader also implements io.Closer.
+func NewNumberedLineReader(b *Buffer, start int) io.Reader {
+	return &numberedLineReader{src: NewReader(b).(*reader), n: start}
+}
+
+func (r *numberedLineReader) Read(p []byte) (int, error) {
+	var chunk [512]byte
+	for len(r.out) == 0 && r.err == nil {
+		n, err := r.src.Read(chunk[:])
+		r.split(chunk[:n])
+		if isEnd(err) && len(r.line) > 0 {
+			r.emit()
+		}
+		r.err = err
+	}
+
+	if len(r.out) == 0 {
+		return 0, r.err
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// split appends complete lines of p to the output and keeps the rest.
+func (r *numberedLineReader) split(p []byte) {
+	for {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			r.line = append(r.line, p...)
+			return
+		}
+		r.line = append(r.line, p[:i]...)
+		r.emit()
+		p = p[i+1:]
+	}
+}
+
+func (r *numberedLineReader) emit() {
+	r.out = strconv.AppendInt(r.out, int64(r.n), 10)
+	r.out = append(r.out, '\t')
+	r.out = append(r.out, r.line...)
+	r.out = append(r.out, '\n')
+	r.line = r.line[:0]
+	r.n++
+}
+
+// Close releases the reader from the buffer.
+func (r *numberedLineReader) Close() error {
+	return r.src.Close()
+}
+
+func (r *numberedLineReader) self() *reader {
+	return r.src
+}
@@ -0,0 +1,43 @@
+package buffer
+
+import (
+	"io"
+	"testing"
+
+	"github.com/pxi/is"
+)
+
+func TestNumberedLineReader(t *testing.T) {
+	b := &Buffer{}
+	r := NewNumberedLineReader(b, 7)
+
+	is.Ok(t, write(b, "one\ntw"))
+	is.Ok(t, write(b, "o\nthree"))
+	is.Ok(t, b.Close())
+
+	p, err := io.ReadAll(r)
+	is.Ok(t, err)
+	is.Equal(t, string(p), "7\tone\n8\ttwo\n9\tthree\n")
+
+	// The end of the message terminates the final line too.
+	b.Reset()
+	r = NewNumberedLineReader(b, 1)
+	is.Ok(t, write(b, "a"))
+	b.EndMessage()
+	s, err := read(r, 64)
+	is.Ok(t, err)
+	is.Equal(t, s, "1\ta\n")
+	_, err = read(r, 64)
+	is.Equal(t, err, ErrEndOfMessage)
+
+	// Reset is reported after the complete lines.
+	b.Reset()
+	r = NewNumberedLineReader(b, 1)
+	is.Ok(t, write(b, "a\nb"))
+	s, err = read(r, 64)
+	is.Ok(t, err)
+	is.Equal(t, s, "1\ta\n")
+	b.Reset()
+	_, err = read(r, 64)
+	is.Equal(t, err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,41 @@
+package buffer
+
+import "time"
+
+// modeWindow is the shortest period over which Mode compares backlogs.
+const modeWindow = 100 * time.Millisecond
+
+// BufferMode tells whether readers are falling behind the writer.
+type BufferMode int
+
+const (
+	// ModeFilling means the backlog of the slowest reader is growing.
+	ModeFilling BufferMode = iota + 1
+
+	// ModeDraining means the backlog of the slowest reader is not growing.
+	ModeDraining
+)
+
+// backlog is the unread byte count of the slowest reader at a time.
+type backlog struct {
+	at time.Time
+	n  int
+}
+
+// Mode reports whether the backlog of the slowest live reader has grown
+// since it was sampled at least modeWindow ago by an earlier call. The first
+// call compares against an empty backlog.
+func (b *Buffer) Mode() BufferMode {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := backlog{at: time.Now(), n: len(b.buf) - b.slowest()}
+	if now.at.Sub(b.backlogs[1].at) >= modeWindow {
+		b.backlogs[0], b.backlogs[1] = b.backlogs[1], now
+	}
+
+	if now.n > b.backlogs[0].n {
+		return ModeFilling
+	}
+	return ModeDraining
+}
@@ -0,0 +1,19 @@
+package buffer
+
+import (
+	"testing"
+
+	"github.com/pxi/is"
+)
+
+func TestMode(t *testing.T) {
+	b := &Buffer{}
+	r := NewReader(b)
+
+	is.Ok(t, write(b, w1))
+	is.Equal(t, b.Mode(), ModeFilling)
+
+	_, err := read(r, 8)
+	is.Ok(t, err)
+	is.Equal(t, b.Mode(), ModeDraining)
+}
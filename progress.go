@@ -0,0 +1,34 @@
+package buffer
+
+import "io"
+
+type progressReader struct {
+	src   *reader
+	read  int64
+	total int64
+	fn    func(read, total int64)
+}
+
+// NewProgressReader returns a new io.Reader that emits the whole b and calls
+// fn after every Read with the bytes read so far and total. total is passed
+// through as given and may be anything if the size is not known. The Read
+// returning io.EOF makes a final call. The reader also implements io.Closer.
+func NewProgressReader(b *Buffer, total int64, fn func(read, total int64)) io.Reader {
+	return &progressReader{src: NewReader(b).(*reader), total: total, fn: fn}
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.src.Read(p)
+	r.read += int64(n)
+	r.fn(r.read, r.total)
+	return n, err
+}
+
+// Close releases the reader from the buffer.
+func (r *progressReader) Close() error {
+	return r.src.Close()
+}
+
+func (r *progressReader) self() *reader {
+	return r.src
+}
@@ -0,0 +1,29 @@
+package buffer
+
+import (
+	"io"
+	"testing"
+
+	"github.com/pxi/is"
+)
+
+func TestProgressReader(t *testing.T) {
+	b := &Buffer{}
+
+	var calls [][2]int64
+	r := NewProgressReader(b, 4, func(read, total int64) {
+		calls = append(calls, [2]int64{read, total})
+	})
+
+	is.Ok(t, write(b, w1))
+	_, err := read(r, 8)
+	is.Ok(t, err)
+	is.Ok(t, write(b, w2))
+	is.Ok(t, b.Close())
+	_, err = read(r, 8)
+	is.Ok(t, err)
+	_, err = read(r, 8)
+	is.Equal(t, err, io.EOF)
+
+	is.Equal(t, calls, [][2]int64{{2, 4}, {4, 4}, {4, 4}})
+}
@@ -0,0 +1,122 @@
+package buffer
+
+import (
+	"errors"
+	"io"
+	"time"
+)
+
+// errFraming is returned from EnableFraming if the buffer is not empty.
+var errFraming = errors.New("buffer: framing enabled on non-empty buffer")
+
+// EnableFraming switches the buffer to record framing where every subsequent
+// Write appends one record. It fails if bytes were already written since the
+// last Reset, as they could not be told apart from records. Framing stays
+// enabled across Reset.
+func (b *Buffer) EnableFraming() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.buf) > 0 {
+		return errFraming
+	}
+	b.framed = true
+	return nil
+}
+
+// errNotFramed is returned from reading records of an unframed buffer.
+var errNotFramed = errors.New("buffer: records read from unframed buffer")
+
+type recordReader struct {
+	src *reader
+	rec int
+}
+
+// NewRecordReader returns a new io.Reader that emits the records of a framed
+// b, one record per Read. If p is too small for the next record Read returns
+// io.ErrShortBuffer and the record is left unread. Read fails unless framing
+// is enabled on b. The reader also implements io.Closer.
+func NewRecordReader(b *Buffer) io.Reader {
+	return &recordReader{src: NewReader(b).(*reader)}
+}
+
+func (r *recordReader) Read(p []byte) (n int, err error) {
+	rr := r.src
+	defer func() { rr.drained(err) }()
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+
+	if err := r.await(); err != nil {
+		return 0, err
+	}
+
+	end := rr.ends[r.rec]
+	if len(p) < end-rr.off {
+		return 0, io.ErrShortBuffer
+	}
+
+	n = copy(p, rr.buf[rr.off:end])
+	r.advance()
+
+	return n, nil
+}
+
+// Close releases the reader from the buffer.
+func (r *recordReader) Close() error {
+	return r.src.Close()
+}
+
+func (r *recordReader) self() *reader {
+	return r.src
+}
+
+// next appends the next record to p.
+func (r *recordReader) next(p []byte) (_ []byte, err error) {
+	rr := r.src
+	defer func() { rr.drained(err) }()
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+
+	if err := r.await(); err != nil {
+		return p, err
+	}
+
+	p = append(p, rr.buf[rr.off:rr.ends[r.rec]]...)
+	r.advance()
+
+	return p, nil
+}
+
+// await waits for the next record and returns io.EOF or ErrEndOfMessage at
+// the end of records or io.ErrUnexpectedEOF on reset. Must be called with the
+// read lock held.
+func (r *recordReader) await() error {
+	rr := r.src
+	for (rr.ended() == nil && len(rr.ends) == r.rec) && (rr.gone() == nil) && rr.framed {
+		rr.wait(time.Time{})
+	}
+
+	// Return unexpected eof if buffer was reset.
+	if err := rr.gone(); err != nil {
+		return err
+	}
+
+	if !rr.framed {
+		return errNotFramed
+	}
+
+	// Return EOF if buffer reported EOF with no records left.
+	if len(rr.ends) == r.rec {
+		return rr.ended()
+	}
+
+	return nil
+}
+
+// advance moves past the next record. Must be called with the read lock
+// held.
+func (r *recordReader) advance() {
+	rr := r.src
+	rr.off = rr.ends[r.rec]
+	r.rec++
+	rr.progressed()
+}
@@ -0,0 +1,49 @@
+package buffer
+
+import (
+	"io"
+	"testing"
+
+	"github.com/pxi/is"
+)
+
+func TestRecordReader(t *testing.T) {
+	b := &Buffer{}
+
+	// Raw preamble prevents framing until reset.
+	is.Ok(t, write(b, "hello"))
+	is.Equal(t, b.EnableFraming(), errFraming)
+	b.Reset()
+	is.Ok(t, b.EnableFraming())
+
+	r := NewRecordReader(b)
+	is.Ok(t, write(b, w1))
+	is.Ok(t, write(b, w2+w3))
+	is.Ok(t, b.Close())
+
+	s, err := read(r, 8)
+	is.Ok(t, err)
+	is.Equal(t, s, w1)
+
+	_, err = read(r, 2)
+	is.Equal(t, err, io.ErrShortBuffer)
+
+	s, err = read(r, 8)
+	is.Ok(t, err)
+	is.Equal(t, s, w2+w3)
+
+	_, err = read(r, 8)
+	is.Equal(t, err, io.EOF)
+
+	// Only records are exposed.
+	_, ok := r.(interface{ ReadVec(...[]byte) (int, error) })
+	is.Equal(t, ok, false)
+	is.Ok(t, r.(io.Closer).Close())
+
+	// Unframed buffers have no records to read.
+	b = &Buffer{}
+	r = NewRecordReader(b)
+	is.Ok(t, write(b, w1))
+	_, err = read(r, 8)
+	is.Equal(t, err, errNotFramed)
+}
@@ -0,0 +1,96 @@
+package buffer
+
+import "sync"
+
+// ReliableReader is a reader that delivers at most a window of bytes beyond
+// those acknowledged. Unacknowledged bytes are delivered again after
+// Reattach.
+type ReliableReader struct {
+	r      *reader
+	window int
+
+	mu    sync.Mutex
+	sent  int
+	ack   int
+	acked chan struct{}
+}
+
+// NewReliableReader returns a new ReliableReader that emits the whole b but
+// waits for acknowledgements once windowBytes bytes are unacknowledged.
+// windowBytes must be positive.
+func (b *Buffer) NewReliableReader(windowBytes int) *ReliableReader {
+	return &ReliableReader{
+		r:      NewReader(b).(*reader),
+		window: windowBytes,
+		acked:  make(chan struct{}),
+	}
+}
+
+// Read reads up to len(p) bytes, waiting first for acknowledgements if the
+// window is full and then for data as a buffer reader does. Closing the
+// reader, or resetting or closing the buffer, ends the wait for
+// acknowledgements as it would end a wait for data.
+func (rr *ReliableReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	rr.mu.Lock()
+	for rr.sent-rr.ack >= rr.window {
+		acked := rr.acked
+		rr.mu.Unlock()
+		if err := rr.r.waitFor(acked); err != nil {
+			rr.r.drained(err)
+			return 0, err
+		}
+		rr.mu.Lock()
+	}
+	if n := rr.ack + rr.window - rr.sent; n < len(p) {
+		p = p[:n]
+	}
+	rr.mu.Unlock()
+
+	n, err := rr.r.Read(p)
+
+	rr.mu.Lock()
+	rr.sent += n
+	rr.mu.Unlock()
+
+	return n, err
+}
+
+// Ack acknowledges the first upTo bytes of the stream as processed, opening
+// the window for more. The acknowledged offset never moves backwards nor past
+// the bytes delivered. Ack may be called concurrently with Read.
+func (rr *ReliableReader) Ack(upTo int) {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	if upTo > rr.sent {
+		upTo = rr.sent
+	}
+	if upTo > rr.ack {
+		rr.ack = upTo
+		rr.r.Ack(upTo)
+		close(rr.acked)
+		rr.acked = make(chan struct{})
+	}
+}
+
+// Reattach rewinds the reader to the last acknowledged offset so that
+// unacknowledged bytes are delivered again. It must not be called
+// concurrently with Read.
+func (rr *ReliableReader) Reattach() {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	rr.r.Reattach()
+	rr.sent = rr.ack
+}
+
+// Close releases the reader from the buffer.
+func (rr *ReliableReader) Close() error {
+	return rr.r.Close()
+}
+
+func (rr *ReliableReader) self() *reader {
+	return rr.r
+}
@@ -0,0 +1,68 @@
+package buffer
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pxi/is"
+)
+
+func TestReliableReader(t *testing.T) {
+	b := &Buffer{}
+	r := b.NewReliableReader(4)
+	is.Ok(t, write(b, w1+w2+w3))
+
+	s, err := read(r, 8)
+	is.Ok(t, err)
+	is.Equal(t, s, w1+w2)
+
+	// A full window waits for an acknowledgement.
+	done := make(chan string)
+	go func() {
+		s, _ := read(r, 8)
+		done <- s
+	}()
+	time.Sleep(time.Millisecond)
+	select {
+	case <-done:
+		t.Fatal("read past the window")
+	default:
+	}
+	r.Ack(1)
+	is.Equal(t, <-done, "c")
+
+	// Unacknowledged bytes are delivered again.
+	r.Reattach()
+	s, err = read(r, 8)
+	is.Ok(t, err)
+	is.Equal(t, s, "abbc")
+}
+
+func TestReliableReaderWindowWait(t *testing.T) {
+	for _, tt := range []struct {
+		end  func(b *Buffer, r *ReliableReader)
+		want error
+	}{
+		{func(b *Buffer, r *ReliableReader) { r.Close() }, errReaderClosed},
+		{func(b *Buffer, r *ReliableReader) { b.Reset() }, io.ErrUnexpectedEOF},
+		{func(b *Buffer, r *ReliableReader) { b.Close() }, io.EOF},
+	} {
+		b := &Buffer{}
+		r := b.NewReliableReader(2)
+		is.Ok(t, write(b, w1))
+		s, err := read(r, 8)
+		is.Ok(t, err)
+		is.Equal(t, s, w1)
+
+		// The wait for acknowledgements ends with the reader or buffer.
+		done := make(chan error)
+		go func() {
+			_, err := read(r, 8)
+			done <- err
+		}()
+		time.Sleep(time.Millisecond)
+		tt.end(b, r)
+		is.Equal(t, <-done, tt.want)
+	}
+}
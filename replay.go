@@ -0,0 +1,79 @@
+package buffer
+
+import (
+	"io"
+	"time"
+)
+
+type replayReader struct {
+	src   *recordReader
+	speed float64
+	tsOf  func([]byte) time.Time
+	start time.Time
+	first time.Time
+	out   []byte
+}
+
+// NewReplaySpeedReader returns a new io.Reader that emits the records of a
+// framed b paced by their timestamps, as extracted by tsOf. The gaps between
+// records are divided by speed, so 1 replays in real time and 2 twice as fast.
+// A speed of zero or less disables pacing. Once b is closed or the message
+// ends the remaining records are emitted without pacing. Reset interrupts
+// pacing with io.ErrUnexpectedEOF. The reader also implements io.Closer.
+func NewReplaySpeedReader(b *Buffer, speed float64, tsOf func([]byte) time.Time) io.Reader {
+	return &replayReader{
+		src:   NewRecordReader(b).(*recordReader),
+		speed: speed,
+		tsOf:  tsOf,
+	}
+}
+
+func (r *replayReader) Read(p []byte) (int, error) {
+	if len(r.out) == 0 {
+		rec, err := r.src.next(nil)
+		if err != nil {
+			return 0, err
+		}
+		if err := r.pace(r.tsOf(rec)); err != nil {
+			return 0, err
+		}
+		r.out = rec
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// pace waits until a record stamped ts is due.
+func (r *replayReader) pace(ts time.Time) error {
+	if r.start.IsZero() {
+		r.start, r.first = time.Now(), ts
+		return nil
+	}
+	if r.speed <= 0 {
+		return nil
+	}
+
+	due := r.start.Add(time.Duration(float64(ts.Sub(r.first)) / r.speed))
+
+	rr := r.src.src
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+	for rr.ended() == nil && rr.gone() == nil && time.Now().Before(due) {
+		rr.wait(due)
+	}
+	if err := rr.gone(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Close releases the reader from the buffer.
+func (r *replayReader) Close() error {
+	return r.src.Close()
+}
+
+func (r *replayReader) self() *reader {
+	return r.src.src
+}
@@ -0,0 +1,58 @@
+package buffer
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pxi/is"
+)
+
+func TestReplaySpeedReader(t *testing.T) {
+	// Records are a single digit of milliseconds.
+	tsOf := func(rec []byte) time.Time {
+		return time.UnixMilli(int64(rec[0] - '0'))
+	}
+
+	b := &Buffer{}
+	is.Ok(t, b.EnableFraming())
+	r := NewReplaySpeedReader(b, 0.5, tsOf)
+	is.Ok(t, write(b, "0"))
+	is.Ok(t, write(b, "5"))
+
+	// The 5ms gap is replayed at half speed.
+	start := time.Now()
+	for _, want := range []string{"0", "5"} {
+		s, err := read(r, 8)
+		is.Ok(t, err)
+		is.Equal(t, s, want)
+	}
+	is.Content(t, time.Since(start) >= 10*time.Millisecond, "records not paced")
+
+	// The end of the message stops pacing.
+	b.Reset()
+	r = NewReplaySpeedReader(b, 0.001, tsOf)
+	is.Ok(t, write(b, "0"))
+	is.Ok(t, write(b, "9"))
+	b.EndMessage()
+	start = time.Now()
+	for _, want := range []string{"0", "9"} {
+		s, err := read(r, 8)
+		is.Ok(t, err)
+		is.Equal(t, s, want)
+	}
+	is.Content(t, time.Since(start) < time.Second, "records paced past the end of the message")
+	b.Reset()
+
+	// Reset interrupts pacing.
+	r = NewReplaySpeedReader(b, 0.001, tsOf)
+	is.Ok(t, write(b, "9"))
+	go func() {
+		time.Sleep(time.Millisecond)
+		b.Reset()
+	}()
+	_, err := read(r, 8)
+	is.Ok(t, err)
+	_, err = read(r, 8)
+	is.Equal(t, err, io.ErrUnexpectedEOF)
+}
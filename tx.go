@@ -0,0 +1,87 @@
+package buffer
+
+import "errors"
+
+// errTxDone is returned from Tx methods once the transaction has ended.
+var errTxDone = errors.New("buffer: transaction has already been committed or rolled back")
+
+// Tx stages writes to a buffer until they are committed. A Tx is not safe for
+// concurrent use.
+type Tx struct {
+	b    *Buffer
+	buf  []byte
+	done bool
+}
+
+// Begin starts a transaction on the buffer. A buffer has at most one open
+// transaction: Begin blocks until the previous one is committed or rolled
+// back. Plain writes are not affected by an open transaction. Readers only
+// ever see committed bytes; a reader that has read everything committed
+// waits through the transaction as it would on an idle buffer.
+func (b *Buffer) Begin() *Tx {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for b.tx != nil {
+		tx := b.tx
+		b.mu.Unlock()
+		<-tx
+		b.mu.Lock()
+	}
+	b.tx = make(chan struct{})
+	return &Tx{b: b}
+}
+
+// Write stages p. Staged bytes are not visible to readers until Commit.
+func (tx *Tx) Write(p []byte) (int, error) {
+	if tx.done {
+		return 0, errTxDone
+	}
+	tx.buf = append(tx.buf, p...)
+	return len(p), nil
+}
+
+// Commit appends the staged bytes to the buffer as a single write and ends
+// the transaction. The staged bytes are discarded if the buffer does not
+// accept writes.
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return errTxDone
+	}
+	tx.done = true
+
+	b := tx.b
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.endTx()
+
+	if err := b.admit(len(tx.buf)); err != nil {
+		return err
+	}
+	if len(tx.buf) > 0 {
+		b.append(tx.buf)
+	}
+	tx.buf = nil
+	return nil
+}
+
+// Rollback discards the staged bytes and ends the transaction.
+func (tx *Tx) Rollback() error {
+	if tx.done {
+		return errTxDone
+	}
+	tx.done = true
+	tx.buf = nil
+
+	b := tx.b
+	b.mu.Lock()
+	b.endTx()
+	b.mu.Unlock()
+	return nil
+}
+
+// endTx ends the open transaction and wakes a Begin waiting for it. Must be
+// called with the write lock held.
+func (b *Buffer) endTx() {
+	close(b.tx)
+	b.tx = nil
+}
@@ -0,0 +1,89 @@
+package buffer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pxi/is"
+)
+
+func TestTx(t *testing.T) {
+	b := &Buffer{}
+	r := NewReader(b)
+
+	tx := b.Begin()
+	is.Ok(t, write(tx, w1))
+	is.Ok(t, write(tx, w2))
+	is.Equal(t, b.Len(), 0)
+
+	// A second transaction waits for the first.
+	next := make(chan *Tx)
+	go func() { next <- b.Begin() }()
+	time.Sleep(time.Millisecond)
+
+	is.Ok(t, tx.Commit())
+	is.Equal(t, tx.Commit(), errTxDone)
+	s, err := read(r, 8)
+	is.Ok(t, err)
+	is.Equal(t, s, w1+w2)
+
+	tx = <-next
+	is.Ok(t, write(tx, w3))
+	is.Ok(t, tx.Rollback())
+	is.Equal(t, write(tx, w3), errTxDone)
+	is.Equal(t, b.String(), w1+w2)
+}
+
+func TestTxReaderWaitsForCommit(t *testing.T) {
+	b := &Buffer{}
+	r := NewReader(b)
+
+	done := make(chan string)
+	go func() {
+		s, _ := read(r, 8)
+		done <- s
+	}()
+
+	tx := b.Begin()
+	for _, w := range []string{w1, w2, w3} {
+		is.Ok(t, write(tx, w))
+		time.Sleep(time.Millisecond)
+		select {
+		case s := <-done:
+			t.Fatalf("reader woke with %q during transaction", s)
+		default:
+		}
+	}
+
+	is.Ok(t, tx.Commit())
+	is.Equal(t, <-done, w1+w2+w3)
+}
+
+func TestTxSignalBatch(t *testing.T) {
+	b := &Buffer{}
+	b.SetSignalBatchSize(2)
+	r := NewReader(b)
+
+	done := make(chan string)
+	go func() {
+		s, _ := read(r, 8)
+		done <- s
+	}()
+	time.Sleep(time.Millisecond)
+
+	// A commit counts as one write of the batch and ends the transaction
+	// regardless.
+	tx := b.Begin()
+	is.Ok(t, write(tx, w1))
+	is.Ok(t, tx.Commit())
+	is.Ok(t, b.Begin().Rollback())
+	time.Sleep(time.Millisecond)
+	select {
+	case s := <-done:
+		t.Fatalf("reader woke with %q before the batch was full", s)
+	default:
+	}
+
+	is.Ok(t, write(b, w2))
+	is.Equal(t, <-done, w1+w2)
+}
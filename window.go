@@ -0,0 +1,86 @@
+package buffer
+
+import (
+	"io"
+	"time"
+)
+
+type timeWindowReader struct {
+	src    *recordReader
+	window time.Duration
+	tsOf   func([]byte) time.Time
+	head   []byte
+	out    []byte
+	err    error
+}
+
+// NewTimeWindowReader returns a new io.Reader that emits the records of a
+// framed b in batches spanning window. tsOf extracts the timestamp of a
+// record. A batch starts with the first record not yet emitted and takes
+// every following record until one is at least window past the first. At
+// EOF or the end of the message the final partial batch is emitted. The
+// reader also implements io.Closer.
+func NewTimeWindowReader(b *Buffer, window time.Duration, tsOf func([]byte) time.Time) io.Reader {
+	return &timeWindowReader{
+		src:    NewRecordReader(b).(*recordReader),
+		window: window,
+		tsOf:   tsOf,
+	}
+}
+
+func (r *timeWindowReader) Read(p []byte) (int, error) {
+	if len(r.out) == 0 && r.err == nil {
+		r.fill()
+	}
+
+	if len(r.out) == 0 {
+		return 0, r.err
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+// fill collects the next batch into out.
+func (r *timeWindowReader) fill() {
+	batch := r.head
+	r.head = nil
+
+	var start time.Time
+	if len(batch) > 0 {
+		start = r.tsOf(batch)
+	}
+
+	for {
+		rec, err := r.src.next(nil)
+		if err != nil {
+			if isEnd(err) {
+				r.out = batch
+			}
+			r.err = err
+			return
+		}
+
+		ts := r.tsOf(rec)
+		if len(batch) == 0 {
+			batch, start = rec, ts
+			continue
+		}
+		if ts.Sub(start) >= r.window {
+			r.head = rec
+			r.out = batch
+			return
+		}
+		batch = append(batch, rec...)
+	}
+}
+
+// Close releases the reader from the buffer.
+func (r *timeWindowReader) Close() error {
+	return r.src.Close()
+}
+
+func (r *timeWindowReader) self() *reader {
+	return r.src.src
+}
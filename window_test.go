@@ -0,0 +1,52 @@
+package buffer
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/pxi/is"
+)
+
+func TestTimeWindowReader(t *testing.T) {
+	b := &Buffer{}
+	is.Ok(t, b.EnableFraming())
+
+	// Records are a single digit of seconds.
+	tsOf := func(rec []byte) time.Time {
+		return time.Unix(int64(rec[0]-'0'), 0)
+	}
+	r := NewTimeWindowReader(b, 2*time.Second, tsOf)
+
+	for _, rec := range []string{"0", "1", "2", "5", "6", "7"} {
+		is.Ok(t, write(b, rec))
+	}
+	is.Ok(t, b.Close())
+
+	for _, want := range []string{"01", "2", "56", "7"} {
+		s, err := read(r, 8)
+		is.Ok(t, err)
+		is.Equal(t, s, want)
+	}
+	_, err := read(r, 8)
+	is.Equal(t, err, io.EOF)
+
+	// The end of the message emits the partial batch too.
+	b.Reset()
+	r = NewTimeWindowReader(b, 2*time.Second, tsOf)
+	is.Ok(t, write(b, "0"))
+	b.EndMessage()
+	s, err := read(r, 8)
+	is.Ok(t, err)
+	is.Equal(t, s, "0")
+	_, err = read(r, 8)
+	is.Equal(t, err, ErrEndOfMessage)
+
+	// Reset discards the partial batch.
+	b.Reset()
+	r = NewTimeWindowReader(b, 2*time.Second, tsOf)
+	is.Ok(t, write(b, "0"))
+	b.Reset()
+	_, err = read(r, 8)
+	is.Equal(t, err, io.ErrUnexpectedEOF)
+}